@@ -0,0 +1,812 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// how often to check a calendar file for changes
+const calendarRecheckInterval = time.Minute
+
+type CalendarConfig struct {
+	// path to a local .ics file
+	File string `json:"file"`
+
+	// regular expression matched against event summaries, case insensitive.
+	// empty matches every event.
+	Match string `json:"match"`
+
+	// how long before an event starts to begin fading in, and how long after
+	// it ends to finish fading out
+	FadeIn  string `json:"fade_in"`
+	FadeOut string `json:"fade_out"`
+}
+
+// Compile loads the calendar through calendars, so lights sharing a config
+// share the file. A nil cache loads it fresh.
+func (c CalendarConfig) Compile(calendars *calendarCache) (*CalendarOverlay, error) {
+	var errs ValidationErrors
+	overlay := &CalendarOverlay{}
+
+	if c.File == "" {
+		errs.Add("file", errors.New("is required"))
+	} else {
+		overlay.calendar = collect(&errs, "file", calendars.load, c.File)
+	}
+
+	overlay.match = collect(&errs, "match", func(s string) (*regexp.Regexp, error) {
+		re, err := regexp.Compile("(?i)" + s)
+		if err != nil {
+			return nil, fmt.Errorf("invalid pattern %q: %w", s, err)
+		}
+
+		return re, nil
+	}, c.Match)
+
+	overlay.fadeIn = collect(&errs, "fade_in", parseEventFade, c.FadeIn)
+	overlay.fadeOut = collect(&errs, "fade_out", parseEventFade, c.FadeOut)
+
+	return overlay, errs.Err()
+}
+
+func parseEventFade(s string) (time.Duration, error) {
+	if s == "" {
+		return 0, nil
+	}
+
+	d, err := parseDuration(s)
+	if err != nil {
+		return 0, err
+	}
+
+	if d < 0 {
+		return 0, fmt.Errorf("must not be negative, got %s", s)
+	}
+
+	return d, nil
+}
+
+type CalendarOverlay struct {
+	calendar *Calendar
+	match    *regexp.Regexp
+	fadeIn   time.Duration
+	fadeOut  time.Duration
+}
+
+func (c *CalendarOverlay) Mix(now time.Time) float64 {
+	mix := 0.0
+	for _, event := range c.calendar.Events(now) {
+		if !c.match.MatchString(event.Summary) {
+			continue
+		}
+
+		start, end, ok := event.Around(now, c.fadeIn, c.fadeOut)
+		if !ok {
+			continue
+		}
+
+		switch {
+		case now.Before(start): // fading in
+			mix = max(mix, 1.0-start.Sub(now).Seconds()/c.fadeIn.Seconds())
+		case now.Before(end): // happening
+			return 1.0
+		default: // fading out
+			mix = max(mix, 1.0-now.Sub(end).Seconds()/c.fadeOut.Seconds())
+		}
+	}
+
+	return mix
+}
+
+// Calendar is a parsed .ics file that reloads itself when the file changes.
+type Calendar struct {
+	path string
+
+	mu        sync.Mutex
+	events    []CalendarEvent
+	modTime   time.Time
+	lastCheck time.Time
+}
+
+// calendarCache shares calendars between every light compiled from the same
+// config, so a file is only watched once. It lives on the config, so a reload
+// starts over with whatever files the new config names.
+type calendarCache struct {
+	mu        sync.Mutex
+	calendars map[string]*Calendar
+}
+
+func (c *calendarCache) load(path string) (*Calendar, error) {
+	if c == nil {
+		return loadCalendar(path)
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if cal, ok := c.calendars[path]; ok {
+		return cal, nil
+	}
+
+	cal, err := loadCalendar(path)
+	if err != nil {
+		return nil, err
+	}
+
+	if c.calendars == nil {
+		c.calendars = map[string]*Calendar{}
+	}
+
+	c.calendars[path] = cal
+	return cal, nil
+}
+
+func loadCalendar(path string) (*Calendar, error) {
+	cal := &Calendar{path: path}
+	if err := cal.reload(time.Time{}); err != nil {
+		return nil, err
+	}
+
+	return cal, nil
+}
+
+// Events returns the calendar's events, rereading the file if it changed
+// since it was last checked before now.
+func (c *Calendar) Events(now time.Time) []CalendarEvent {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if now.Sub(c.lastCheck) >= calendarRecheckInterval {
+		if err := c.reload(now); err != nil {
+			slog.Warn("failed to reload calendar, keeping previous events", "path", c.path, "err", err)
+		}
+	}
+
+	return c.events
+}
+
+// must be called with the lock held, or before the calendar is shared
+func (c *Calendar) reload(now time.Time) error {
+	c.lastCheck = now
+
+	info, err := os.Stat(c.path)
+	if err != nil {
+		return fmt.Errorf("failed to read calendar: %w", err)
+	}
+
+	if info.ModTime().Equal(c.modTime) {
+		return nil
+	}
+
+	contents, err := os.ReadFile(c.path)
+	if err != nil {
+		return fmt.Errorf("failed to read calendar: %w", err)
+	}
+
+	events, err := ParseCalendar(contents)
+	if err != nil {
+		return err
+	}
+
+	c.events = events
+	c.modTime = info.ModTime()
+	return nil
+}
+
+type Frequency int
+
+const (
+	Once Frequency = iota
+	Daily
+	Weekly
+	Monthly
+	Yearly
+)
+
+// WeekdayRule is a BYDAY entry, a weekday optionally limited to the nth one
+// in the month or year. Negative n counts from the end.
+type WeekdayRule struct {
+	Weekday time.Weekday
+	N       int
+}
+
+// CalendarEvent is a VEVENT, possibly repeating. RRULE is understood as far as
+// FREQ, INTERVAL, COUNT, UNTIL, BYDAY, BYMONTHDAY, BYMONTH and WKST, which
+// covers birthdays, weekends and things like the last friday of the month.
+type CalendarEvent struct {
+	UID      string
+	Summary  string
+	Start    time.Time
+	Duration time.Duration
+
+	// how many days an all day event lasts. Set instead of going by Duration,
+	// since days around daylight saving changes aren't 24 hours long.
+	Days int
+
+	Frequency  Frequency
+	Interval   int
+	Count      int
+	Until      time.Time
+	ByDay      []WeekdayRule
+	ByMonthDay []int
+	ByMonth    []time.Month
+	WeekStart  time.Weekday
+
+	// starts of occurrences that were cancelled with EXDATE or moved by
+	// another event with a RECURRENCE-ID
+	Exceptions []time.Time
+
+	// set when this event replaces a single occurrence of the event with the
+	// same UID
+	RecurrenceID time.Time
+}
+
+// periodStart is midnight on the first day of the kth period, the day, week,
+// month or year occurrences are generated in.
+func (e *CalendarEvent) periodStart(k int) time.Time {
+	n := k * e.Interval
+	y, m, d := e.Start.Date()
+	l := e.Start.Location()
+
+	switch e.Frequency {
+	case Weekly:
+		// back to the start of the week DTSTART falls in
+		d -= (int(e.Start.Weekday()) - int(e.WeekStart) + 7) % 7
+		return time.Date(y, m, d+7*n, 0, 0, 0, 0, l)
+	case Monthly:
+		return time.Date(y, m+time.Month(n), 1, 0, 0, 0, 0, l)
+	case Yearly:
+		return time.Date(y+n, time.January, 1, 0, 0, 0, 0, l)
+	default:
+		return time.Date(y, m, d+n, 0, 0, 0, 0, l)
+	}
+}
+
+// occurrences returns the starts of every occurrence in the kth period, in
+// order.
+func (e *CalendarEvent) occurrences(k int) []time.Time {
+	if e.Frequency == Once {
+		if k > 0 {
+			return nil
+		}
+
+		return []time.Time{e.Start}
+	}
+
+	first := e.periodStart(k)
+	y, m, d := first.Date()
+
+	days := 1
+	switch e.Frequency {
+	case Weekly:
+		days = 7
+	case Monthly:
+		days = daysIn(y, m)
+	case Yearly:
+		days = yearLength(y)
+	}
+
+	hour, minute, sec := e.Start.Clock()
+
+	starts := []time.Time{}
+	for i := range days {
+		start := time.Date(y, m, d+i, hour, minute, sec, e.Start.Nanosecond(), e.Start.Location())
+		if !start.Before(e.Start) && e.matches(start) {
+			starts = append(starts, start)
+		}
+	}
+
+	return starts
+}
+
+// matches reports whether the rule's BY parts allow an occurrence on t's day.
+// Within the period a part either picks the days, like BYDAY in a weekly
+// rule, or narrows them down, like BYMONTH in a daily one, but either way it
+// comes down to every given part agreeing with the day.
+func (e *CalendarEvent) matches(t time.Time) bool {
+	y, m, d := t.Date()
+
+	if len(e.ByMonth) > 0 && !slices.Contains(e.ByMonth, m) {
+		return false
+	}
+
+	if len(e.ByMonthDay) > 0 && !slices.ContainsFunc(e.ByMonthDay, func(n int) bool {
+		return n == d || n == d-daysIn(y, m)-1
+	}) {
+		return false
+	}
+
+	if len(e.ByDay) > 0 {
+		// the nth weekday counts within the year only for yearly rules that
+		// aren't limited to some months
+		yearly := e.Frequency == Yearly && len(e.ByMonth) == 0
+
+		return slices.ContainsFunc(e.ByDay, func(rule WeekdayRule) bool {
+			if rule.Weekday != t.Weekday() {
+				return false
+			}
+
+			switch {
+			case rule.N > 0 && yearly:
+				return (t.YearDay()-1)/7+1 == rule.N
+			case rule.N > 0:
+				return (d-1)/7+1 == rule.N
+			case rule.N < 0 && yearly:
+				return (yearLength(y)-t.YearDay())/7+1 == -rule.N
+			case rule.N < 0:
+				return (daysIn(y, m)-d)/7+1 == -rule.N
+			default:
+				return true
+			}
+		})
+	}
+
+	// without any parts saying which days, it repeats on DTSTART's
+	if len(e.ByMonthDay) > 0 {
+		return true
+	}
+
+	switch e.Frequency {
+	case Weekly:
+		return t.Weekday() == e.Start.Weekday()
+	case Monthly:
+		return d == e.Start.Day()
+	case Yearly:
+		return d == e.Start.Day() && (len(e.ByMonth) > 0 || m == e.Start.Month())
+	default:
+		return true
+	}
+}
+
+func daysIn(y int, m time.Month) int {
+	return time.Date(y, m+1, 0, 0, 0, 0, 0, time.UTC).Day()
+}
+
+// daysBetween counts the calendar days from one date to another
+func daysBetween(from, to time.Time) int {
+	fy, fm, fd := from.Date()
+	ty, tm, td := to.Date()
+	return int(time.Date(ty, tm, td, 0, 0, 0, 0, time.UTC).Sub(time.Date(fy, fm, fd, 0, 0, 0, 0, time.UTC)) / (24 * time.Hour))
+}
+
+// the longest time between period starts, used to skip ahead
+func (e *CalendarEvent) period() time.Duration {
+	day := 24 * time.Hour
+	switch e.Frequency {
+	case Daily:
+		return day * time.Duration(e.Interval)
+	case Weekly:
+		return 7 * day * time.Duration(e.Interval)
+	case Monthly:
+		return 31 * day * time.Duration(e.Interval)
+	case Yearly:
+		return 366 * day * time.Duration(e.Interval)
+	default:
+		return 0
+	}
+}
+
+// Around finds the occurrence whose fade window contains now, returning its
+// start and end.
+func (e *CalendarEvent) Around(now time.Time, fadeIn, fadeOut time.Duration) (time.Time, time.Time, bool) {
+	k := 0
+
+	// skip straight to just before now, the period is never shorter than the
+	// real gap so this can't overshoot. COUNT needs every occurrence counted
+	// from the start though.
+	if period := e.period(); period > 0 && e.Count == 0 {
+		k = max(int(now.Sub(e.periodStart(0))/period)-1, 0)
+	}
+
+	seen := 0
+	for ; ; k++ {
+		// a rule that never matches would otherwise search forever
+		if e.periodStart(k).Add(-fadeIn).After(now) {
+			return time.Time{}, time.Time{}, false
+		}
+
+		for _, start := range e.occurrences(k) {
+			// cancelled occurrences still count towards COUNT
+			if e.Count > 0 && seen >= e.Count {
+				return time.Time{}, time.Time{}, false
+			}
+			seen++
+
+			if !e.Until.IsZero() && start.After(e.Until) {
+				return time.Time{}, time.Time{}, false
+			}
+
+			if start.Add(-fadeIn).After(now) {
+				return time.Time{}, time.Time{}, false
+			}
+
+			if slices.ContainsFunc(e.Exceptions, start.Equal) {
+				continue
+			}
+
+			end := e.end(start)
+			if end.Add(fadeOut).After(now) {
+				return start, end, true
+			}
+		}
+
+		if e.Frequency == Once {
+			return time.Time{}, time.Time{}, false
+		}
+	}
+}
+
+// end is when the occurrence starting at start is over.
+func (e *CalendarEvent) end(start time.Time) time.Time {
+	if e.Days > 0 {
+		y, m, d := start.Date()
+		return time.Date(y, m, d+e.Days, 0, 0, 0, 0, start.Location())
+	}
+
+	return start.Add(e.Duration)
+}
+
+// ParseCalendar reads the events out of an iCalendar file.
+func ParseCalendar(contents []byte) ([]CalendarEvent, error) {
+	events := []CalendarEvent{}
+
+	var event *CalendarEvent
+	var end time.Time
+	var hasDuration, allDay, endsOnDate bool
+
+	// components nested in the current event, like VALARM, whose properties
+	// must not be mistaken for the event's
+	nested := 0
+
+	for i, line := range unfoldLines(contents) {
+		name, params, value := splitProperty(line)
+
+		if name == "BEGIN" && value == "VEVENT" {
+			event = &CalendarEvent{Interval: 1}
+			end, hasDuration, allDay, endsOnDate = time.Time{}, false, false, false
+			nested = 0
+			continue
+		}
+
+		if event == nil {
+			continue
+		}
+
+		if name == "BEGIN" {
+			nested++
+			continue
+		}
+
+		if nested > 0 {
+			if name == "END" {
+				nested--
+			}
+
+			continue
+		}
+
+		var err error
+		switch name {
+		case "END":
+			if value != "VEVENT" {
+				continue
+			}
+
+			if event.Start.IsZero() {
+				return nil, fmt.Errorf("event %q on line %d has no DTSTART", event.Summary, i+1)
+			}
+
+			switch {
+			case hasDuration:
+			case !end.IsZero():
+				event.Duration = end.Sub(event.Start)
+				if allDay && endsOnDate {
+					event.Days = daysBetween(event.Start, end)
+				}
+			case allDay:
+				event.Duration = 24 * time.Hour
+				event.Days = 1
+			}
+
+			events = append(events, *event)
+			event = nil
+		case "UID":
+			event.UID = value
+		case "SUMMARY":
+			event.Summary = unescapeText(value)
+		case "DTSTART":
+			event.Start, allDay, err = parseCalendarTime(params, value)
+		case "DTEND":
+			end, endsOnDate, err = parseCalendarTime(params, value)
+		case "DURATION":
+			event.Duration, err = parseCalendarDuration(value)
+			hasDuration = true
+		case "RRULE":
+			err = parseRule(event, value)
+		case "EXDATE":
+			var exceptions []time.Time
+			exceptions, err = parseCalendarTimes(params, value)
+			event.Exceptions = append(event.Exceptions, exceptions...)
+		case "RECURRENCE-ID":
+			if params["RANGE"] != "" {
+				err = fmt.Errorf("unsupported RANGE %q", params["RANGE"])
+				break
+			}
+
+			event.RecurrenceID, _, err = parseCalendarTime(params, value)
+		case "RDATE", "EXRULE":
+			err = errors.New("not supported")
+		}
+
+		if err != nil {
+			return nil, fmt.Errorf("invalid %s on line %d: %w", name, i+1, err)
+		}
+	}
+
+	return withOverrides(events), nil
+}
+
+// withOverrides cancels the occurrences that events with a RECURRENCE-ID
+// replace, leaving the replacements as one off events of their own.
+func withOverrides(events []CalendarEvent) []CalendarEvent {
+	for _, override := range events {
+		if override.RecurrenceID.IsZero() || override.UID == "" {
+			continue
+		}
+
+		for i := range events {
+			if events[i].UID == override.UID && events[i].RecurrenceID.IsZero() {
+				events[i].Exceptions = append(events[i].Exceptions, override.RecurrenceID)
+			}
+		}
+	}
+
+	return events
+}
+
+// lines with continuation lines folded back in. the index of each line is
+// close enough to the line number for error messages.
+func unfoldLines(contents []byte) []string {
+	lines := []string{}
+
+	scanner := bufio.NewScanner(bytes.NewReader(contents))
+	for scanner.Scan() {
+		line := strings.TrimRight(scanner.Text(), "\r")
+		if (strings.HasPrefix(line, " ") || strings.HasPrefix(line, "\t")) && len(lines) > 0 {
+			lines[len(lines)-1] += line[1:]
+			continue
+		}
+
+		lines = append(lines, line)
+	}
+
+	return lines
+}
+
+// split "DTSTART;TZID=America/Chicago:20250101T090000" into its name,
+// parameters and value
+func splitProperty(line string) (string, map[string]string, string) {
+	head, value, _ := strings.Cut(line, ":")
+	parts := strings.Split(head, ";")
+
+	params := map[string]string{}
+	for _, param := range parts[1:] {
+		key, val, _ := strings.Cut(param, "=")
+		params[strings.ToUpper(key)] = strings.Trim(val, `"`)
+	}
+
+	return strings.ToUpper(parts[0]), params, value
+}
+
+var textUnescaper = strings.NewReplacer(`\n`, "\n", `\N`, "\n", `\,`, ",", `\;`, ";", `\\`, `\`)
+
+func unescapeText(s string) string {
+	return textUnescaper.Replace(s)
+}
+
+// parseCalendarTime handles UTC, TZID and floating date times, plus all day
+// dates. Floating times are taken to be in the configured location.
+func parseCalendarTime(params map[string]string, value string) (time.Time, bool, error) {
+	if params["VALUE"] == "DATE" || len(value) == len("20060102") {
+		t, err := time.ParseInLocation("20060102", value, loc)
+		return t, true, err
+	}
+
+	if strings.HasSuffix(value, "Z") {
+		t, err := time.Parse("20060102T150405Z", value)
+		return t, false, err
+	}
+
+	l := loc
+	if tzid, ok := params["TZID"]; ok {
+		var err error
+		l, err = time.LoadLocation(tzid)
+		if err != nil {
+			return time.Time{}, false, fmt.Errorf("unknown TZID %q", tzid)
+		}
+	}
+
+	t, err := time.ParseInLocation("20060102T150405", value, l)
+	return t, false, err
+}
+
+var calendarDurationPattern = regexp.MustCompile(`^([+-])?P(?:(\d+)W)?(?:(\d+)D)?(?:T(?:(\d+)H)?(?:(\d+)M)?(?:(\d+)S)?)?$`)
+
+// parse an iCalendar duration like P1DT2H or PT30M
+func parseCalendarDuration(value string) (time.Duration, error) {
+	m := calendarDurationPattern.FindStringSubmatch(value)
+	if m == nil || value == "P" || value == "PT" {
+		return 0, fmt.Errorf("invalid duration %q", value)
+	}
+
+	units := []time.Duration{7 * 24 * time.Hour, 24 * time.Hour, time.Hour, time.Minute, time.Second}
+
+	var d time.Duration
+	for i, unit := range units {
+		if m[i+2] == "" {
+			continue
+		}
+
+		n, _ := strconv.Atoi(m[i+2])
+		d += time.Duration(n) * unit
+	}
+
+	if m[1] == "-" {
+		d = -d
+	}
+
+	return d, nil
+}
+
+var weekdayCodes = map[string]time.Weekday{
+	"SU": time.Sunday,
+	"MO": time.Monday,
+	"TU": time.Tuesday,
+	"WE": time.Wednesday,
+	"TH": time.Thursday,
+	"FR": time.Friday,
+	"SA": time.Saturday,
+}
+
+func parseRule(event *CalendarEvent, value string) error {
+	hasFrequency := false
+
+	for _, part := range strings.Split(value, ";") {
+		key, val, _ := strings.Cut(part, "=")
+		val = strings.ToUpper(val)
+
+		switch strings.ToUpper(key) {
+		case "FREQ":
+			hasFrequency = true
+
+			switch val {
+			case "DAILY":
+				event.Frequency = Daily
+			case "WEEKLY":
+				event.Frequency = Weekly
+			case "MONTHLY":
+				event.Frequency = Monthly
+			case "YEARLY":
+				event.Frequency = Yearly
+			default:
+				return fmt.Errorf("unsupported frequency %q", val)
+			}
+		case "INTERVAL":
+			n, err := strconv.Atoi(val)
+			if err != nil || n < 1 {
+				return fmt.Errorf("invalid interval %q", val)
+			}
+
+			event.Interval = n
+		case "COUNT":
+			n, err := strconv.Atoi(val)
+			if err != nil || n < 1 {
+				return fmt.Errorf("invalid count %q", val)
+			}
+
+			event.Count = n
+		case "UNTIL":
+			until, allDay, err := parseCalendarTime(map[string]string{}, val)
+			if err != nil {
+				return fmt.Errorf("invalid until %q", val)
+			}
+
+			// a date includes the whole day
+			if allDay {
+				until = until.AddDate(0, 0, 1).Add(-time.Nanosecond)
+			}
+
+			event.Until = until
+		case "BYDAY":
+			for _, day := range strings.Split(val, ",") {
+				code := day[max(len(day)-2, 0):]
+				weekday, ok := weekdayCodes[code]
+				if !ok {
+					return fmt.Errorf("invalid weekday %q", day)
+				}
+
+				rule := WeekdayRule{Weekday: weekday}
+				if ordinal := day[:len(day)-2]; ordinal != "" {
+					n, err := strconv.Atoi(ordinal)
+					if err != nil || n == 0 || n < -53 || n > 53 {
+						return fmt.Errorf("invalid weekday %q", day)
+					}
+
+					rule.N = n
+				}
+
+				event.ByDay = append(event.ByDay, rule)
+			}
+		case "BYMONTHDAY":
+			for _, day := range strings.Split(val, ",") {
+				n, err := strconv.Atoi(day)
+				if err != nil || n == 0 || n < -31 || n > 31 {
+					return fmt.Errorf("invalid day of the month %q", day)
+				}
+
+				event.ByMonthDay = append(event.ByMonthDay, n)
+			}
+		case "BYMONTH":
+			for _, month := range strings.Split(val, ",") {
+				n, err := strconv.Atoi(month)
+				if err != nil || n < 1 || n > 12 {
+					return fmt.Errorf("invalid month %q", month)
+				}
+
+				event.ByMonth = append(event.ByMonth, time.Month(n))
+			}
+		case "WKST":
+			weekday, ok := weekdayCodes[val]
+			if !ok {
+				return fmt.Errorf("invalid week start %q", val)
+			}
+
+			event.WeekStart = weekday
+		default:
+			// anything else would change which occurrences happen, so
+			// ignoring it would put the overlay on the wrong days
+			return fmt.Errorf("unsupported rule part %q", key)
+		}
+	}
+
+	if !hasFrequency {
+		return errors.New("missing FREQ")
+	}
+
+	for _, rule := range event.ByDay {
+		if rule.N != 0 && event.Frequency != Monthly && event.Frequency != Yearly {
+			return fmt.Errorf("numbered weekdays like %d%s only work with monthly and yearly rules", rule.N, strings.ToUpper(rule.Weekday.String()[:2]))
+		}
+	}
+
+	if len(event.ByMonthDay) > 0 && event.Frequency == Weekly {
+		return errors.New("BYMONTHDAY can't be used with weekly rules")
+	}
+
+	return nil
+}
+
+// parseCalendarTimes parses a comma separated list like EXDATE's
+func parseCalendarTimes(params map[string]string, value string) ([]time.Time, error) {
+	times := []time.Time{}
+	for _, v := range strings.Split(value, ",") {
+		t, _, err := parseCalendarTime(params, v)
+		if err != nil {
+			return nil, err
+		}
+
+		times = append(times, t)
+	}
+
+	return times, nil
+}
@@ -0,0 +1,335 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+const testCalendar = "BEGIN:VCALENDAR\r\n" +
+	"VERSION:2.0\r\n" +
+	"BEGIN:VEVENT\r\n" +
+	"SUMMARY:Alex's Birthday\r\n" +
+	"DTSTART;VALUE=DATE:19900704\r\n" +
+	"RRULE:FREQ=YEARLY\r\n" +
+	"BEGIN:VALARM\r\n" +
+	"TRIGGER:-PT15M\r\n" +
+	"DURATION:PT5M\r\n" +
+	"END:VALARM\r\n" +
+	"END:VEVENT\r\n" +
+	"BEGIN:VEVENT\r\n" +
+	"SUMMARY:Game day\\, home\r\n" +
+	"DTSTART;TZID=America/Chicago:20250907T120000\r\n" +
+	"DURATION:PT3H\r\n" +
+	"RRULE:FREQ=WEEKLY;COUNT=3\r\n" +
+	"END:VEVENT\r\n" +
+	"BEGIN:VEVENT\r\n" +
+	"SUMMARY:Party\r\n" +
+	"DTSTART:20251231T230000Z\r\n" +
+	"DTEND:20260101T050000Z\r\n" +
+	"END:VEVENT\r\n" +
+	"END:VCALENDAR\r\n"
+
+func TestParseCalendar(t *testing.T) {
+	events, err := ParseCalendar([]byte(testCalendar))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(events) != 3 {
+		t.Fatalf("expected 3 events, got %d", len(events))
+	}
+
+	if events[0].Duration != 24*time.Hour || events[0].Frequency != Yearly {
+		t.Fatalf("birthday: got %+v", events[0])
+	}
+
+	if events[1].Summary != "Game day, home" || events[1].Duration != 3*time.Hour || events[1].Count != 3 {
+		t.Fatalf("game day: got %+v", events[1])
+	}
+
+	if events[2].Duration != 6*time.Hour {
+		t.Fatalf("party: got %+v", events[2])
+	}
+}
+
+func TestCalendarOverlay(t *testing.T) {
+	l := useLocation(t, "America/Chicago")
+
+	path := filepath.Join(t.TempDir(), "house.ics")
+	if err := os.WriteFile(path, []byte(testCalendar), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	overlay, err := CalendarConfig{File: path, Match: "game day", FadeIn: "1h", FadeOut: "30m"}.Compile(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	cases := []struct {
+		at   time.Time
+		want float64
+	}{
+		{time.Date(2025, time.September, 14, 10, 30, 0, 0, l), 0},   // before the fade
+		{time.Date(2025, time.September, 14, 11, 30, 0, 0, l), 0.5}, // fading in
+		{time.Date(2025, time.September, 14, 13, 0, 0, 0, l), 1},    // during
+		{time.Date(2025, time.September, 14, 15, 15, 0, 0, l), 0.5}, // fading out
+		{time.Date(2025, time.September, 28, 13, 0, 0, 0, l), 0},    // past the count
+	}
+
+	for _, c := range cases {
+		if got := overlay.Mix(c.at); !almostEqual(got, c.want) {
+			t.Fatalf("mix at %s: got %f want %f", c.at, got, c.want)
+		}
+	}
+
+	birthday, err := CalendarConfig{File: path, Match: "birthday"}.Compile(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if got := birthday.Mix(time.Date(2025, time.July, 4, 20, 0, 0, 0, l)); got != 1 {
+		t.Fatalf("birthday: got %f", got)
+	}
+
+	if got := birthday.Mix(time.Date(2025, time.July, 5, 1, 0, 0, 0, l)); got != 0 {
+		t.Fatalf("day after birthday: got %f", got)
+	}
+}
+
+// calendarEvent wraps VEVENT properties in a calendar and parses it
+func calendarEvent(lines ...string) ([]CalendarEvent, error) {
+	contents := "BEGIN:VCALENDAR\r\nBEGIN:VEVENT\r\n" + strings.Join(lines, "\r\n") + "\r\nEND:VEVENT\r\nEND:VCALENDAR\r\n"
+	return ParseCalendar([]byte(contents))
+}
+
+func TestCalendarRecurrenceRules(t *testing.T) {
+	l := useLocation(t, "UTC")
+	day := func(year int, month time.Month, d int) time.Time {
+		return time.Date(year, month, d, 12, 0, 0, 0, l)
+	}
+
+	cases := []struct {
+		name string
+		rule string
+		on   []time.Time
+		off  []time.Time
+	}{
+		{
+			// dtstart is a friday
+			name: "weekends",
+			rule: "RRULE:FREQ=WEEKLY;BYDAY=SA,SU",
+			on:   []time.Time{day(2025, time.March, 8), day(2025, time.March, 9), day(2025, time.June, 15)},
+			off:  []time.Time{day(2025, time.March, 7), day(2025, time.March, 10), day(2025, time.March, 1)},
+		},
+		{
+			// the week of dtstart counts even though its tuesday came before
+			name: "every other week",
+			rule: "RRULE:FREQ=WEEKLY;INTERVAL=2;BYDAY=TU;WKST=MO",
+			on:   []time.Time{day(2025, time.March, 18), day(2025, time.April, 1)},
+			off:  []time.Time{day(2025, time.March, 4), day(2025, time.March, 11), day(2025, time.March, 25)},
+		},
+		{
+			name: "last friday of the month",
+			rule: "RRULE:FREQ=MONTHLY;BYDAY=-1FR",
+			on:   []time.Time{day(2025, time.March, 28), day(2025, time.May, 30)},
+			off:  []time.Time{day(2025, time.March, 21), day(2025, time.May, 23)},
+		},
+		{
+			name: "last day of the month",
+			rule: "RRULE:FREQ=MONTHLY;BYMONTHDAY=-1",
+			on:   []time.Time{day(2025, time.March, 31), day(2025, time.April, 30), day(2028, time.February, 29)},
+			off:  []time.Time{day(2025, time.April, 1), day(2028, time.February, 28)},
+		},
+		{
+			name: "thanksgiving",
+			rule: "RRULE:FREQ=YEARLY;BYMONTH=11;BYDAY=4TH",
+			on:   []time.Time{day(2025, time.November, 27), day(2026, time.November, 26)},
+			off:  []time.Time{day(2025, time.November, 20), day(2026, time.November, 27)},
+		},
+		{
+			name: "summer weekdays",
+			rule: "RRULE:FREQ=DAILY;BYMONTH=6,7,8;BYDAY=MO,TU,WE,TH,FR",
+			on:   []time.Time{day(2025, time.June, 2), day(2025, time.August, 29)},
+			off:  []time.Time{day(2025, time.June, 1), day(2025, time.September, 1), day(2025, time.May, 30)},
+		},
+		{
+			name: "weekends with a cancelled one",
+			rule: "RRULE:FREQ=WEEKLY;BYDAY=SA,SU\r\nEXDATE:20250315T110000Z,20250316T110000Z",
+			on:   []time.Time{day(2025, time.March, 8), day(2025, time.March, 22)},
+			off:  []time.Time{day(2025, time.March, 15), day(2025, time.March, 16)},
+		},
+		{
+			name: "count includes cancelled occurrences",
+			rule: "RRULE:FREQ=DAILY;COUNT=3\r\nEXDATE:20250308T110000Z",
+			on:   []time.Time{day(2025, time.March, 7), day(2025, time.March, 9)},
+			off:  []time.Time{day(2025, time.March, 8), day(2025, time.March, 10)},
+		},
+	}
+
+	for _, c := range cases {
+		events, err := calendarEvent("UID:test", "DTSTART:20250307T110000Z", "DURATION:PT2H", c.rule)
+		if err != nil {
+			t.Fatalf("%s: %v", c.name, err)
+		}
+
+		for _, at := range c.on {
+			if _, _, ok := events[0].Around(at, 0, 0); !ok {
+				t.Fatalf("%s: expected an occurrence at %s", c.name, at)
+			}
+		}
+
+		for _, at := range c.off {
+			if _, _, ok := events[0].Around(at, 0, 0); ok {
+				t.Fatalf("%s: expected no occurrence at %s", c.name, at)
+			}
+		}
+	}
+}
+
+func TestCalendarRecurrenceID(t *testing.T) {
+	useLocation(t, "UTC")
+
+	contents := "BEGIN:VCALENDAR\r\n" +
+		"BEGIN:VEVENT\r\n" +
+		"UID:standup\r\n" +
+		"SUMMARY:Game night\r\n" +
+		"DTSTART:20250307T190000Z\r\n" +
+		"DURATION:PT3H\r\n" +
+		"RRULE:FREQ=WEEKLY\r\n" +
+		"END:VEVENT\r\n" +
+		"BEGIN:VEVENT\r\n" +
+		"UID:standup\r\n" +
+		"SUMMARY:Game night\r\n" +
+		"RECURRENCE-ID:20250314T190000Z\r\n" +
+		"DTSTART:20250315T190000Z\r\n" +
+		"DURATION:PT3H\r\n" +
+		"END:VEVENT\r\n" +
+		"END:VCALENDAR\r\n"
+
+	events, err := ParseCalendar([]byte(contents))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	active := func(at time.Time) bool {
+		for _, event := range events {
+			if _, _, ok := event.Around(at, 0, 0); ok {
+				return true
+			}
+		}
+
+		return false
+	}
+
+	moved := time.Date(2025, time.March, 14, 20, 0, 0, 0, time.UTC)
+	if active(moved) || !active(moved.AddDate(0, 0, 1)) || !active(moved.AddDate(0, 0, 7)) {
+		t.Fatalf("expected the 14th to move to the 15th, got %+v", events)
+	}
+}
+
+func TestCalendarRejectsUnsupportedRecurrence(t *testing.T) {
+	for _, line := range []string{
+		"RRULE:FREQ=MONTHLY;BYDAY=MO,TU,WE,TH,FR;BYSETPOS=-1",
+		"RRULE:FREQ=YEARLY;BYWEEKNO=20",
+		"RRULE:FREQ=HOURLY",
+		"RRULE:FREQ=WEEKLY;BYDAY=2MO",
+		"RRULE:FREQ=WEEKLY;BYMONTHDAY=1",
+		"RRULE:COUNT=3",
+		"RDATE:20250401T110000Z",
+		"RECURRENCE-ID;RANGE=THISANDFUTURE:20250314T110000Z",
+	} {
+		if _, err := calendarEvent("DTSTART:20250307T110000Z", line); err == nil {
+			t.Fatalf("expected %s to be rejected", line)
+		}
+	}
+}
+
+func TestAllDayEventsAcrossDaylightSaving(t *testing.T) {
+	l := useLocation(t, "America/Chicago")
+
+	events, err := ParseCalendar([]byte("BEGIN:VCALENDAR\r\n" +
+		"BEGIN:VEVENT\r\n" +
+		"SUMMARY:Spring forward\r\n" +
+		"DTSTART;VALUE=DATE:20250309\r\n" +
+		"END:VEVENT\r\n" +
+		"BEGIN:VEVENT\r\n" +
+		"SUMMARY:Fall back\r\n" +
+		"DTSTART;VALUE=DATE:20241103\r\n" +
+		"DTEND;VALUE=DATE:20241104\r\n" +
+		"RRULE:FREQ=YEARLY\r\n" +
+		"END:VEVENT\r\n" +
+		"END:VCALENDAR\r\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// the 23 and 25 hour days still end at midnight, and a 25 hour one doesn't
+	// make the next year's last longer
+	cases := []struct {
+		event int
+		at    time.Time
+		want  bool
+	}{
+		{0, time.Date(2025, time.March, 9, 23, 30, 0, 0, l), true},
+		{0, time.Date(2025, time.March, 10, 0, 30, 0, 0, l), false},
+		{1, time.Date(2024, time.November, 3, 23, 30, 0, 0, l), true},
+		{1, time.Date(2025, time.November, 3, 23, 30, 0, 0, l), true},
+		{1, time.Date(2025, time.November, 4, 0, 30, 0, 0, l), false},
+	}
+
+	for _, c := range cases {
+		if _, _, ok := events[c.event].Around(c.at, 0, 0); ok != c.want {
+			t.Fatalf("%s at %s: got %t want %t", events[c.event].Summary, c.at, ok, c.want)
+		}
+	}
+}
+
+func TestCalendarsAreSharedPerConfig(t *testing.T) {
+	useLocation(t, "UTC")
+
+	path := filepath.Join(t.TempDir(), "house.ics")
+	if err := os.WriteFile(path, []byte(testCalendar), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	calendar := CalendarConfig{File: path, Match: "party"}
+	first, second := defaultConfig(), defaultConfig()
+
+	a, errA := calendar.Compile(first.calendars)
+	b, errB := calendar.Compile(first.calendars)
+	c, errC := calendar.Compile(second.calendars)
+	if errA != nil || errB != nil || errC != nil {
+		t.Fatal(errA, errB, errC)
+	}
+
+	if a.calendar != b.calendar || a.calendar == c.calendar {
+		t.Fatal("expected lights to share a calendar only within the same config")
+	}
+
+	// rereading the file goes by the time the overlay is asked about
+	now := time.Date(2025, time.December, 31, 23, 30, 0, 0, time.UTC)
+	if a.Mix(now) != 1 {
+		t.Fatal("expected the party to be on")
+	}
+
+	moved := strings.ReplaceAll(testCalendar, "SUMMARY:Party", "SUMMARY:Quiet night")
+	if err := os.WriteFile(path, []byte(moved), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	later := time.Now().Add(time.Hour)
+	if err := os.Chtimes(path, later, later); err != nil {
+		t.Fatal(err)
+	}
+
+	if a.Mix(now.Add(calendarRecheckInterval/2)) != 1 {
+		t.Fatal("expected the calendar not to be reread before the recheck interval")
+	}
+
+	if a.Mix(now.Add(calendarRecheckInterval)) != 0 {
+		t.Fatal("expected the calendar to be reread once the recheck interval passed")
+	}
+}
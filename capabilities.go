@@ -0,0 +1,217 @@
+package main
+
+import (
+	"math"
+	"slices"
+)
+
+// Z2MExpose is one entry in a device definition's exposes, describing
+// something the device can report or be set to. Lights nest their settings
+// under features.
+type Z2MExpose struct {
+	Type     string      `json:"type"`
+	Name     string      `json:"name"`
+	Property string      `json:"property"`
+	ValueMin *float64    `json:"value_min"`
+	ValueMax *float64    `json:"value_max"`
+	Features []Z2MExpose `json:"features"`
+}
+
+type Z2MDefinition struct {
+	Model   string      `json:"model"`
+	Vendor  string      `json:"vendor"`
+	Exposes []Z2MExpose `json:"exposes"`
+}
+
+// the widest range of color temperatures zigbee2mqtt accepts, used when a
+// device doesn't report its own
+const (
+	defaultMiredsMin = 150
+	defaultMiredsMax = 500
+)
+
+// MiredRange is the span of color temperatures a tunable white bulb supports.
+type MiredRange struct {
+	min, max int
+}
+
+// Capabilities is what lumos can send to a device.
+type Capabilities struct {
+	xy         bool
+	hs         bool
+	colorTemp  *MiredRange
+	brightness bool
+
+	// set for models configured as rejecting a transition in their set payload
+	noTransition bool
+
+	// nil sends colors as they are
+	gamut *Gamut
+}
+
+type PayloadFormat string
+
+const (
+	FormatXY         PayloadFormat = "xy"
+	FormatHS         PayloadFormat = "hs"
+	FormatColorTemp  PayloadFormat = "color_temp"
+	FormatBrightness PayloadFormat = "brightness"
+)
+
+// Capabilities reads what the device supports out of its exposes. Returns
+// false for anything lumos can't drive, like plugs, sensors and on/off only
+// lights.
+func (d Z2MDevice) Capabilities() (Capabilities, bool) {
+	light := d.lightExpose()
+	if light == nil {
+		return Capabilities{}, false
+	}
+
+	caps := Capabilities{}
+	for _, feature := range light.Features {
+		switch feature.Name {
+		case "color_xy":
+			caps.xy = true
+		case "color_hs":
+			caps.hs = true
+		case "brightness":
+			caps.brightness = true
+		case "color_temp":
+			mireds := &MiredRange{min: defaultMiredsMin, max: defaultMiredsMax}
+			if feature.ValueMin != nil {
+				mireds.min = int(*feature.ValueMin)
+			}
+
+			if feature.ValueMax != nil {
+				mireds.max = int(*feature.ValueMax)
+			}
+
+			caps.colorTemp = mireds
+		}
+	}
+
+	return caps, caps.Format() != ""
+}
+
+// IsModel is whether the device is any of models, by zigbee model id or
+// zigbee2mqtt model.
+func (d Z2MDevice) IsModel(models []string) bool {
+	if d.ModelID != "" && slices.Contains(models, d.ModelID) {
+		return true
+	}
+
+	return d.Definition != nil && d.Definition.Model != "" && slices.Contains(models, d.Definition.Model)
+}
+
+func (d Z2MDevice) lightExpose() *Z2MExpose {
+	if d.Definition == nil {
+		return nil
+	}
+
+	for i := range d.Definition.Exposes {
+		if d.Definition.Exposes[i].Type == "light" {
+			return &d.Definition.Exposes[i]
+		}
+	}
+
+	return nil
+}
+
+// intersect narrows down to what both devices support, for sending one command
+// to a group of them.
+func (c Capabilities) intersect(other Capabilities) Capabilities {
+	out := Capabilities{
+		xy:         c.xy && other.xy,
+		hs:         c.hs && other.hs,
+		brightness: c.brightness && other.brightness,
+
+		noTransition: c.noTransition || other.noTransition,
+	}
+
+	if c.colorTemp != nil && other.colorTemp != nil {
+		mireds := MiredRange{
+			min: max(c.colorTemp.min, other.colorTemp.min),
+			max: min(c.colorTemp.max, other.colorTemp.max),
+		}
+
+		if mireds.min <= mireds.max {
+			out.colorTemp = &mireds
+		}
+	}
+
+	// there's no single triangle for bulbs with different gamuts, so only fit
+	// when they agree
+	if c.gamut != nil && other.gamut != nil && *c.gamut == *other.gamut {
+		out.gamut = c.gamut
+	}
+
+	return out
+}
+
+// Format picks how colors are sent, preferring whatever keeps the most of the
+// color. Empty if the device can't show colors or brightness at all.
+func (c Capabilities) Format() PayloadFormat {
+	switch {
+	case c.xy:
+		return FormatXY
+	case c.hs:
+		return FormatHS
+	case c.colorTemp != nil:
+		return FormatColorTemp
+	case c.brightness:
+		return FormatBrightness
+	default:
+		return ""
+	}
+}
+
+// Mireds projects a color onto the closest point on the black body curve the
+// bulb can show. Closeness is measured in CIE 1960 uv, the space correlated
+// color temperature is defined in.
+func (r MiredRange) Mireds(color Oklch) int {
+	u, v := xyToUV(color.ToXY())
+
+	best, bestDist := r.min, math.Inf(1)
+	for mireds := r.min; mireds <= r.max; mireds++ {
+		pu, pv := xyToUV(planckianXY(1e6 / float64(mireds)))
+		dist := (u-pu)*(u-pu) + (v-pv)*(v-pv)
+		if dist < bestDist {
+			best, bestDist = mireds, dist
+		}
+	}
+
+	return best
+}
+
+func xyToUV(x, y float64) (u, v float64) {
+	d := -2*x + 12*y + 3
+	if d == 0 {
+		return 0, 0
+	}
+
+	return 4 * x / d, 6 * y / d
+}
+
+// planckianXY approximates the chromaticity of a black body at kelvin, using
+// the cubic spline from Kim et al. It's only valid between 1667K and 25000K,
+// so anything outside is clamped.
+func planckianXY(kelvin float64) (x, y float64) {
+	t := min(max(kelvin, 1667), 25000)
+
+	if t <= 4000 {
+		x = -0.2661239e9/(t*t*t) - 0.2343589e6/(t*t) + 0.8776956e3/t + 0.179910
+	} else {
+		x = -3.0258469e9/(t*t*t) + 2.1070379e6/(t*t) + 0.2226347e3/t + 0.240390
+	}
+
+	switch {
+	case t <= 2222:
+		y = -1.1063814*x*x*x - 1.34811020*x*x + 2.18555832*x - 0.20219683
+	case t <= 4000:
+		y = -0.9549476*x*x*x - 1.37418593*x*x + 2.09137015*x - 0.16748867
+	default:
+		y = 3.0817580*x*x*x - 5.87338670*x*x + 3.75112997*x - 0.37001483
+	}
+
+	return x, y
+}
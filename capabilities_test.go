@@ -0,0 +1,179 @@
+package main
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+func TestMiredsProjection(t *testing.T) {
+	white := OklchFromSRGB(1, 1, 1)
+
+	// d65 sits at about 6500K, or 154 mireds
+	if got := (MiredRange{min: 150, max: 500}).Mireds(white); got < 150 || got > 160 {
+		t.Fatalf("white: expected about 154 mireds, got %d", got)
+	}
+
+	// bulbs that can't go that cool get as close as they can
+	if got := (MiredRange{min: 250, max: 454}).Mireds(white); got != 250 {
+		t.Fatalf("white on a warm bulb: expected 250 mireds, got %d", got)
+	}
+
+	warm := OklchFromSRGB(1, 0.6, 0.25)
+	if got := (MiredRange{min: 150, max: 500}).Mireds(warm); got < 400 {
+		t.Fatalf("orange: expected a warm temperature, got %d mireds", got)
+	}
+}
+
+func TestDeviceCapabilities(t *testing.T) {
+	var device Z2MDevice
+	payload := `{
+		"friendly_name": "hallway",
+		"definition": {"exposes": [{
+			"type": "light",
+			"features": [
+				{"type": "binary", "name": "state"},
+				{"type": "numeric", "name": "brightness", "value_min": 0, "value_max": 254},
+				{"type": "numeric", "name": "color_temp", "value_min": 153, "value_max": 454}
+			]
+		}]}
+	}`
+
+	if err := json.Unmarshal([]byte(payload), &device); err != nil {
+		t.Fatalf("failed to parse device: %v", err)
+	}
+
+	caps, ok := device.Capabilities()
+	if !ok || caps.Format() != FormatColorTemp || caps.colorTemp.min != 153 || caps.colorTemp.max != 454 {
+		t.Fatalf("expected a 153-454 mired bulb, got %+v", caps)
+	}
+
+	cmd := NewCommand(Target{Color: OklchFromSRGB(1, 1, 1)}, caps, 0)
+	if cmd.Color != nil || cmd.ColorTemp == nil || *cmd.ColorTemp < 153 || *cmd.ColorTemp > 160 {
+		t.Fatalf("expected a color_temp command, got %s", cmd.Payload())
+	}
+
+	// transition isn't listed as an option, but is still sent
+	if cmd := NewCommand(Target{Color: OklchFromSRGB(1, 1, 1)}, caps, 2); cmd.Transition != 2 {
+		t.Fatalf("expected a transition, got %s", cmd.Payload())
+	}
+}
+
+func TestNoTransitionModels(t *testing.T) {
+	previous := config
+	t.Cleanup(func() { config = previous })
+	config = Config{NoTransition: []string{"GL-C-008"}}
+
+	var strip, bulb Z2MDevice
+	payload := `{
+		"friendly_name": "shelf",
+		"ieee_address": "0x00124b0022b1c2d3",
+		"model_id": "GL-C-008",
+		"definition": {
+			"model": "GL-C-008",
+			"vendor": "Gledopto",
+			"exposes": [{
+				"type": "light",
+				"features": [
+					{"type": "binary", "name": "state", "property": "state"},
+					{"type": "numeric", "name": "brightness", "property": "brightness", "value_min": 0, "value_max": 254},
+					{"type": "composite", "name": "color_xy", "property": "color"}
+				]
+			}]
+		}
+	}`
+
+	if err := json.Unmarshal([]byte(payload), &strip); err != nil {
+		t.Fatalf("failed to parse device: %v", err)
+	}
+
+	bulb = strip
+	bulb.ModelID = "TRADFRI bulb E27 CWS 806lm"
+	bulb.Definition = &Z2MDefinition{Model: "LED1924G9", Vendor: "IKEA", Exposes: strip.Definition.Exposes}
+
+	target := Target{Color: OklchFromSRGB(1, 0, 0)}
+
+	caps, ok := deviceCapabilities(strip)
+	if cmd := NewCommand(target, caps, 2); !ok || cmd.Transition != 0 {
+		t.Fatalf("expected a listed model not to get a transition, got %s", cmd.Payload())
+	}
+
+	caps, ok = deviceCapabilities(bulb)
+	if cmd := NewCommand(target, caps, 2); !ok || cmd.Transition != 2 {
+		t.Fatalf("expected other models to get a transition, got %s", cmd.Payload())
+	}
+
+	// zigbee2mqtt model names match too
+	config.NoTransition = []string{"LED1924G9"}
+	if caps, _ := deviceCapabilities(bulb); !caps.noTransition {
+		t.Fatalf("expected the zigbee2mqtt model to match, got %+v", caps)
+	}
+}
+
+func TestDeviceCapabilitiesSkipsNonLights(t *testing.T) {
+	var plug Z2MDevice
+	payload := `{
+		"friendly_name": "kettle",
+		"definition": {"exposes": [
+			{"type": "switch", "features": [{"type": "binary", "name": "state"}]},
+			{"type": "numeric", "name": "power"}
+		]}
+	}`
+
+	if err := json.Unmarshal([]byte(payload), &plug); err != nil {
+		t.Fatalf("failed to parse device: %v", err)
+	}
+
+	if caps, ok := plug.Capabilities(); ok {
+		t.Fatalf("expected a plug to be skipped, got %+v", caps)
+	}
+
+	var onOff Z2MDevice
+	payload = `{"definition": {"exposes": [{"type": "light", "features": [{"type": "binary", "name": "state"}]}]}}`
+	if err := json.Unmarshal([]byte(payload), &onOff); err != nil {
+		t.Fatalf("failed to parse device: %v", err)
+	}
+
+	if caps, ok := onOff.Capabilities(); ok {
+		t.Fatalf("expected an on/off light to be skipped, got %+v", caps)
+	}
+}
+
+func TestCommandFormats(t *testing.T) {
+	target := Target{Color: OklchFromSRGB(1, 0, 0), Brightness: 0.5, HasBrightness: true}
+
+	hs := NewCommand(target, Capabilities{hs: true, brightness: true}, 2)
+	if hs.Color == nil || hs.Color.Hue == nil || hs.Color.X != nil || hs.Brightness == nil || hs.Transition != 2 {
+		t.Fatalf("expected hue and saturation, got %s", hs.Payload())
+	}
+
+	// models that reject transitions don't get one, and no brightness support
+	// means no brightness field
+	xy := NewCommand(target, Capabilities{xy: true, noTransition: true}, 2)
+	if xy.Color == nil || xy.Color.X == nil || xy.Brightness != nil || xy.Transition != 0 {
+		t.Fatalf("expected bare xy, got %s", xy.Payload())
+	}
+
+	dimmable := NewCommand(Target{Color: Oklch{L: 0.5}}, Capabilities{brightness: true}, 0)
+	if dimmable.Color != nil || dimmable.Brightness == nil || *dimmable.Brightness != 127 {
+		t.Fatalf("expected brightness following lightness, got %s", dimmable.Payload())
+	}
+}
+
+func TestCapabilitiesIntersect(t *testing.T) {
+	color := Capabilities{xy: true, hs: true, brightness: true, colorTemp: &MiredRange{min: 153, max: 500}}
+	white := Capabilities{brightness: true, noTransition: true, colorTemp: &MiredRange{min: 250, max: 454}}
+
+	caps := color.intersect(white)
+	if caps.Format() != FormatColorTemp || !caps.brightness || !caps.noTransition {
+		t.Fatalf("expected a shared color temperature, got %+v", caps)
+	}
+
+	if caps.colorTemp.min != 250 || caps.colorTemp.max != 454 {
+		t.Fatalf("expected 250-454 mireds, got %+v", caps.colorTemp)
+	}
+
+	plug := Capabilities{}
+	if got := color.intersect(plug).Format(); got != "" {
+		t.Fatalf("expected nothing in common with a plug, got %q", got)
+	}
+}
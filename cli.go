@@ -0,0 +1,234 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"time"
+)
+
+type command struct {
+	usage string
+	run   func(args []string) error
+}
+
+var commands = map[string]command{
+	"validate": {
+		usage: "validate <config.json>",
+		run:   runValidate,
+	},
+	"preview": {
+		usage: "preview <config.json> --group <name> [--group <name>...] [--at <time>]",
+		run:   runPreview,
+	},
+	"simulate": {
+		usage: "simulate <config.json> --out <file.png|file.csv> [--group <name>...] [--from <time>] [--duration 24h] [--step 1m]",
+		run:   runSimulate,
+	},
+}
+
+// errUsage signals that the command was invoked wrong and usage should be
+// printed
+var errUsage = errors.New("invalid usage")
+
+// RunCommand runs a cli subcommand and returns the process exit code.
+func RunCommand(name string, args []string) int {
+	switch name {
+	case "help", "-h", "-help", "--help":
+		printUsage(os.Stdout)
+		return 0
+	}
+
+	cmd, ok := commands[name]
+	if !ok {
+		fmt.Fprintf(os.Stderr, "unknown command %q\n\n", name)
+		printUsage(os.Stderr)
+		return 2
+	}
+
+	if err := cmd.run(args); err != nil {
+		if errors.Is(err, errUsage) {
+			fmt.Fprintf(os.Stderr, "usage: lumos %s\n", cmd.usage)
+			return 2
+		}
+
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+
+	return 0
+}
+
+func printUsage(w io.Writer) {
+	fmt.Fprintln(w, "usage:")
+	fmt.Fprintln(w, "  lumos                run the daemon")
+	for _, name := range []string{"validate", "preview", "simulate"} {
+		fmt.Fprintf(w, "  lumos %s\n", commands[name].usage)
+	}
+}
+
+// parseArgs parses flags that may come before or after a single positional
+// argument, so both `preview config.json --group a` and `preview --group a
+// config.json` work.
+func parseArgs(fs *flag.FlagSet, args []string) (string, error) {
+	var positional []string
+	for {
+		if err := fs.Parse(args); err != nil {
+			return "", errUsage
+		}
+
+		if fs.NArg() == 0 {
+			break
+		}
+
+		positional = append(positional, fs.Arg(0))
+		args = fs.Args()[1:]
+	}
+
+	if len(positional) != 1 {
+		return "", errUsage
+	}
+
+	return positional[0], nil
+}
+
+func loadConfigFile(path string) (Config, error) {
+	contents, err := os.ReadFile(path)
+	if err != nil {
+		return Config{}, fmt.Errorf("failed to read config file: %w", err)
+	}
+
+	cfg, err := ParseConfig(contents)
+	if err != nil {
+		return Config{}, fmt.Errorf("invalid config %s\n%w", path, err)
+	}
+
+	return cfg, nil
+}
+
+func runValidate(args []string) error {
+	fs := flag.NewFlagSet("validate", flag.ContinueOnError)
+	path, err := parseArgs(fs, args)
+	if err != nil {
+		return err
+	}
+
+	if _, err := loadConfigFile(path); err != nil {
+		return err
+	}
+
+	fmt.Printf("%s is valid\n", path)
+	return nil
+}
+
+// layouts accepted by --at, tried in order
+var previewTimeLayouts = []string{
+	time.RFC3339,
+	"2006-01-02 15:04",
+	"2006-01-02T15:04",
+	"2006-01-02",
+}
+
+func parsePreviewTime(s string) (time.Time, error) {
+	for _, layout := range previewTimeLayouts {
+		if t, err := time.ParseInLocation(layout, s, loc); err == nil {
+			return t, nil
+		}
+	}
+
+	return time.Time{}, fmt.Errorf("invalid time %q, expected something like 2025-12-24 18:30", s)
+}
+
+func runPreview(args []string) error {
+	var groups []string
+	at := time.Now().In(loc)
+
+	fs := flag.NewFlagSet("preview", flag.ContinueOnError)
+	fs.Func("group", "zigbee2mqtt group the device belongs to, can be repeated", func(s string) error {
+		groups = append(groups, s)
+		return nil
+	})
+	fs.Func("at", "time to preview, defaults to now", func(s string) error {
+		t, err := parsePreviewTime(s)
+		if err != nil {
+			return err
+		}
+
+		at = t
+		return nil
+	})
+
+	path, err := parseArgs(fs, args)
+	if err != nil {
+		return err
+	}
+
+	if len(groups) == 0 {
+		return errUsage
+	}
+
+	cfg, err := loadConfigFile(path)
+	if err != nil {
+		return err
+	}
+
+	runtime := cfg.Compile(groups)
+
+	fmt.Printf("groups: %s\n", strings.Join(groups, ", "))
+	fmt.Printf("at:     %s\n", at.Format("Mon 2006-01-02 15:04 MST"))
+
+	// overlays are tried in order, so an overlay's chance of being picked is
+	// its mix times the chance every overlay before it was skipped
+	remaining := 1.0
+
+	fmt.Println()
+	fmt.Println("overlays:")
+	if len(runtime.overlays) == 0 {
+		fmt.Println("  none")
+	}
+
+	for i := range runtime.overlays {
+		overlay := &runtime.overlays[i]
+		mix := overlay.Mix(at)
+		chance := remaining * mix
+		remaining -= chance
+
+		state := "inactive"
+		if mix > 0 {
+			state = "active"
+		}
+
+		fmt.Printf("  %s: %s, mix %.2f, picked %.0f%% of the time\n", overlay.name, state, mix, chance*100)
+		printColors(overlay.palette.colors.colors)
+	}
+
+	fmt.Println()
+	fmt.Printf("ambient: picked %.0f%% of the time\n", remaining*100)
+	printColors(runtime.AmbientColors())
+
+	return nil
+}
+
+func printColors(colors []Oklch) {
+	if len(colors) == 0 {
+		fmt.Println("    no colors")
+	}
+
+	for _, color := range colors {
+		fmt.Printf("    %s %s  %s\n", swatch(color), color.Hex(), color)
+	}
+}
+
+// two cells of the color as a truecolor terminal background, or nothing when
+// NO_COLOR is set
+func swatch(color Oklch) string {
+	if _, ok := os.LookupEnv("NO_COLOR"); ok {
+		return ""
+	}
+
+	r, g, b := color.ToSRGB()
+	return fmt.Sprintf("\x1b[48;2;%d;%d;%dm  \x1b[0m", to8Bit(r), to8Bit(g), to8Bit(b))
+}
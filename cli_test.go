@@ -0,0 +1,65 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"io"
+	"slices"
+	"testing"
+)
+
+func TestParseArgs(t *testing.T) {
+	cases := []struct {
+		args   []string
+		path   string
+		groups []string
+		err    error
+	}{
+		{args: []string{"config.json"}, path: "config.json"},
+		{args: []string{"config.json", "--group", "a"}, path: "config.json", groups: []string{"a"}},
+		{args: []string{"--group", "a", "config.json"}, path: "config.json", groups: []string{"a"}},
+		{args: []string{"-group=a", "config.json", "--group", "b"}, path: "config.json", groups: []string{"a", "b"}},
+		{args: []string{}, err: errUsage},
+		{args: []string{"a.json", "b.json"}, err: errUsage},
+		{args: []string{"config.json", "--unknown"}, err: errUsage},
+		{args: []string{"config.json", "--group"}, err: errUsage},
+	}
+
+	for _, c := range cases {
+		var groups []string
+		fs := flag.NewFlagSet("test", flag.ContinueOnError)
+		fs.SetOutput(io.Discard)
+		fs.Func("group", "", func(s string) error {
+			groups = append(groups, s)
+			return nil
+		})
+
+		path, err := parseArgs(fs, c.args)
+		if !errors.Is(err, c.err) {
+			t.Fatalf("%v: got error %v want %v", c.args, err, c.err)
+		}
+
+		if path != c.path || !slices.Equal(groups, c.groups) {
+			t.Fatalf("%v: got %q %v want %q %v", c.args, path, groups, c.path, c.groups)
+		}
+	}
+}
+
+func TestParsePreviewTime(t *testing.T) {
+	useLocation(t, "UTC")
+
+	for _, s := range []string{"2025-12-24T18:30:00Z", "2025-12-24 18:30", "2025-12-24T18:30"} {
+		got, err := parsePreviewTime(s)
+		if err != nil || got.Hour() != 18 || got.Minute() != 30 || got.Day() != 24 {
+			t.Fatalf("%s: got %s, %v", s, got, err)
+		}
+	}
+
+	if got, err := parsePreviewTime("2025-12-24"); err != nil || got.Hour() != 0 {
+		t.Fatalf("date only: got %s, %v", got, err)
+	}
+
+	if _, err := parsePreviewTime("tomorrow"); err == nil {
+		t.Fatal("expected an error for an unparsable time")
+	}
+}
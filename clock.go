@@ -0,0 +1,183 @@
+package main
+
+import (
+	"sync"
+	"time"
+)
+
+// Clock is the source of time for overlays and color managers, so time based
+// logic can be driven by a FakeClock in tests and simulations.
+type Clock interface {
+	Now() time.Time
+	NewTimer(d time.Duration) Timer
+	NewTicker(d time.Duration) Ticker
+	After(d time.Duration) <-chan time.Time
+}
+
+type Timer interface {
+	C() <-chan time.Time
+	Stop() bool
+}
+
+type Ticker interface {
+	C() <-chan time.Time
+	Stop()
+}
+
+var realClock Clock = systemClock{}
+
+type systemClock struct{}
+
+func (systemClock) Now() time.Time {
+	return time.Now()
+}
+
+func (systemClock) NewTimer(d time.Duration) Timer {
+	return systemTimer{time.NewTimer(d)}
+}
+
+func (systemClock) NewTicker(d time.Duration) Ticker {
+	return systemTicker{time.NewTicker(d)}
+}
+
+func (systemClock) After(d time.Duration) <-chan time.Time {
+	return time.After(d)
+}
+
+type systemTimer struct{ *time.Timer }
+
+func (t systemTimer) C() <-chan time.Time {
+	return t.Timer.C
+}
+
+type systemTicker struct{ *time.Ticker }
+
+func (t systemTicker) C() <-chan time.Time {
+	return t.Ticker.C
+}
+
+// FakeClock only moves when told to. Timers and tickers fire as Advance passes
+// their deadlines.
+type FakeClock struct {
+	mu      sync.Mutex
+	now     time.Time
+	waiters []*fakeTimer
+}
+
+func NewFakeClock(now time.Time) *FakeClock {
+	return &FakeClock{now: now}
+}
+
+func (f *FakeClock) Now() time.Time {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	return f.now
+}
+
+// Set jumps the clock to now, firing anything due along the way.
+func (f *FakeClock) Set(now time.Time) {
+	f.Advance(now.Sub(f.Now()))
+}
+
+// Advance moves the clock forward by d, firing every timer and ticker whose
+// deadline is passed.
+func (f *FakeClock) Advance(d time.Duration) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	f.now = f.now.Add(d)
+
+	waiting := f.waiters[:0]
+	for _, w := range f.waiters {
+		if w.stopped {
+			continue
+		}
+
+		if !w.at.After(f.now) {
+			// like the real thing, a slow reader misses ticks rather than
+			// blocking the clock
+			select {
+			case w.c <- f.now:
+			default:
+			}
+
+			if w.period == 0 {
+				w.stopped = true
+				continue
+			}
+
+			for !w.at.After(f.now) {
+				w.at = w.at.Add(w.period)
+			}
+		}
+
+		waiting = append(waiting, w)
+	}
+
+	f.waiters = waiting
+}
+
+func (f *FakeClock) NewTimer(d time.Duration) Timer {
+	return f.add(d, 0)
+}
+
+func (f *FakeClock) NewTicker(d time.Duration) Ticker {
+	if d <= 0 {
+		panic("non-positive interval for NewTicker")
+	}
+
+	return fakeTicker{f.add(d, d)}
+}
+
+func (f *FakeClock) After(d time.Duration) <-chan time.Time {
+	return f.add(d, 0).C()
+}
+
+func (f *FakeClock) add(d, period time.Duration) *fakeTimer {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	w := &fakeTimer{
+		clock:  f,
+		at:     f.now.Add(d),
+		period: period,
+		c:      make(chan time.Time, 1),
+	}
+
+	if d <= 0 {
+		w.c <- f.now
+		w.stopped = true
+		return w
+	}
+
+	f.waiters = append(f.waiters, w)
+	return w
+}
+
+type fakeTimer struct {
+	clock   *FakeClock
+	at      time.Time
+	period  time.Duration
+	c       chan time.Time
+	stopped bool
+}
+
+func (t *fakeTimer) C() <-chan time.Time {
+	return t.c
+}
+
+func (t *fakeTimer) Stop() bool {
+	t.clock.mu.Lock()
+	defer t.clock.mu.Unlock()
+
+	wasActive := !t.stopped
+	t.stopped = true
+	return wasActive
+}
+
+type fakeTicker struct{ *fakeTimer }
+
+func (t fakeTicker) Stop() {
+	t.fakeTimer.Stop()
+}
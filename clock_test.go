@@ -0,0 +1,51 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+func TestFakeClockTimers(t *testing.T) {
+	start := time.Date(2025, time.January, 1, 0, 0, 0, 0, time.UTC)
+	clock := NewFakeClock(start)
+
+	timer := clock.NewTimer(time.Minute)
+	ticker := clock.NewTicker(20 * time.Second)
+	after := clock.After(30 * time.Second)
+
+	clock.Advance(20 * time.Second)
+	select {
+	case <-ticker.C():
+	default:
+		t.Fatal("expected ticker to fire")
+	}
+
+	select {
+	case <-timer.C():
+		t.Fatal("timer fired early")
+	case <-after:
+		t.Fatal("after fired early")
+	default:
+	}
+
+	clock.Advance(40 * time.Second)
+	for name, c := range map[string]<-chan time.Time{"timer": timer.C(), "after": after, "ticker": ticker.C()} {
+		select {
+		case <-c:
+		default:
+			t.Fatalf("expected %s to fire", name)
+		}
+	}
+
+	ticker.Stop()
+	clock.Advance(time.Minute)
+	select {
+	case <-ticker.C():
+		t.Fatal("stopped ticker fired")
+	default:
+	}
+
+	if !clock.Now().Equal(start.Add(2 * time.Minute)) {
+		t.Fatalf("unexpected now %s", clock.Now())
+	}
+}
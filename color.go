@@ -1,6 +1,9 @@
 package main
 
-import "math"
+import (
+	"fmt"
+	"math"
+)
 
 type Oklch struct{ L, C, H float64 } // L in [0..1], H in degrees [0..360)
 
@@ -43,8 +46,12 @@ func lerpHue(a, b, t float64) float64 {
 
 // ---------- sRGB <-> Oklch ----------
 func OklchFromSRGB(r, g, b float64) Oklch {
-	rl, gl, bl := srgbToLinear(r), srgbToLinear(g), srgbToLinear(b)
+	return OklchFromLinearSRGB(srgbToLinear(r), srgbToLinear(g), srgbToLinear(b))
+}
 
+// OklchFromLinearSRGB converts linear sRGB, which may be outside [0, 1] for
+// colors sRGB can't show.
+func OklchFromLinearSRGB(rl, gl, bl float64) Oklch {
 	// linear RGB -> LMS
 	l := 0.4122214708*rl + 0.5363325363*gl + 0.0514459929*bl
 	m := 0.2119034982*rl + 0.6806995451*gl + 0.1073969566*bl
@@ -70,6 +77,18 @@ func OklchFromSRGB(r, g, b float64) Oklch {
 }
 
 func (c Oklch) ToSRGB() (r, g, b float64) {
+	rl, gl, bl := c.toLinearSRGB()
+
+	// linear -> sRGB with clamp
+	r = clamp01(linearToSrgb(rl))
+	g = clamp01(linearToSrgb(gl))
+	b = clamp01(linearToSrgb(bl))
+	return
+}
+
+// linear sRGB without clamping, so colors outside sRGB come out negative or
+// above 1
+func (c Oklch) toLinearSRGB() (rl, gl, bl float64) {
 	h := c.H * math.Pi / 180.0
 	a := c.C * math.Cos(h)
 	b_ := c.C * math.Sin(h)
@@ -84,17 +103,23 @@ func (c Oklch) ToSRGB() (r, g, b float64) {
 	s := s_ * s_ * s_
 
 	// LMS -> linear RGB
-	rl := +4.0767416621*l - 3.3077115913*m + 0.2309699292*s
-	gl := -1.2684380046*l + 2.6097574011*m - 0.3413193965*s
-	bl := -0.0041960863*l - 0.7034186147*m + 1.7076147010*s
-
-	// linear -> sRGB with clamp
-	r = clamp01(linearToSrgb(rl))
-	g = clamp01(linearToSrgb(gl))
-	b = clamp01(linearToSrgb(bl))
+	rl = +4.0767416621*l - 3.3077115913*m + 0.2309699292*s
+	gl = -1.2684380046*l + 2.6097574011*m - 0.3413193965*s
+	bl = -0.0041960863*l - 0.7034186147*m + 1.7076147010*s
 	return
 }
 
+// DistanceTo is the perceptual difference between two colors, the euclidean
+// distance in Oklab.
+func (c Oklch) DistanceTo(o Oklch) float64 {
+	ha, hb := c.H*math.Pi/180, o.H*math.Pi/180
+	da := c.C*math.Cos(ha) - o.C*math.Cos(hb)
+	db := c.C*math.Sin(ha) - o.C*math.Sin(hb)
+	dl := c.L - o.L
+
+	return math.Sqrt(dl*dl + da*da + db*db)
+}
+
 // ---------- Helpers (HSV + xy if you need them) ----------
 
 // ToHSB/HSV via sRGB (h in [0,360), s,v in [0,1])
@@ -103,10 +128,30 @@ func (c Oklch) ToHSV() (h, s, v float64) {
 	return rgbToHSV(r, g, b)
 }
 
+// CSS representation, e.g. oklch(62.8% 0.258 29.23)
+func (c Oklch) String() string {
+	return fmt.Sprintf("oklch(%.1f%% %.3f %.2f)", c.L*100, c.C, c.H)
+}
+
+// sRGB hex string, e.g. #ff8800
+func (c Oklch) Hex() string {
+	r, g, b := c.ToSRGB()
+	return fmt.Sprintf("#%02x%02x%02x", to8Bit(r), to8Bit(g), to8Bit(b))
+}
+
 // CIE 1931 xy (sRGB/D65). If all zero, returns 0,0.
 func (c Oklch) ToXY() (x, y float64) {
 	r, g, b := c.ToSRGB()
-	rl, gl, bl := srgbToLinear(r), srgbToLinear(g), srgbToLinear(b)
+	return linearToXY(srgbToLinear(r), srgbToLinear(g), srgbToLinear(b))
+}
+
+// CIE 1931 xy without clamping to sRGB first, for bulbs that can show more
+// than sRGB.
+func (c Oklch) rawXY() (x, y float64) {
+	return linearToXY(c.toLinearSRGB())
+}
+
+func linearToXY(rl, gl, bl float64) (x, y float64) {
 	X := 0.4124564*rl + 0.3575761*gl + 0.1804375*bl
 	Y := 0.2126729*rl + 0.7151522*gl + 0.0721750*bl
 	Z := 0.0193339*rl + 0.1191920*gl + 0.9503041*bl
@@ -119,6 +164,9 @@ func (c Oklch) ToXY() (x, y float64) {
 
 // ---------- Private utilities ----------
 func srgbToLinear(c float64) float64 {
+	if c < 0 {
+		return -srgbToLinear(-c)
+	}
 	if c <= 0.04045 {
 		return c / 12.92
 	}
@@ -142,6 +190,10 @@ func cbrt(x float64) float64 {
 	return 0
 }
 
+func to8Bit(x float64) uint8 {
+	return uint8(math.Round(clamp01(x) * 255))
+}
+
 func clamp01(x float64) float64 {
 	if x < 0 {
 		return 0
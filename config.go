@@ -1,6 +1,8 @@
 package main
 
 import (
+	"bytes"
+	"context"
 	"encoding/json"
 	"errors"
 	"fmt"
@@ -9,15 +11,30 @@ import (
 	"math/rand/v2"
 	"os"
 	"slices"
-	"strconv"
 	"strings"
 	"time"
 
 	"github.com/BSFishy/lumos/util"
 )
 
+var configPath = lookupConfigPath()
+
+func lookupConfigPath() string {
+	if path, ok := os.LookupEnv("CONFIG_PATH"); ok {
+		return path
+	}
+
+	return "/config/config.json"
+}
+
+const configPollInterval = 5 * time.Second
+
+// contents of the config file that is currently applied, used to tell if it
+// changed
+var configContents []byte
+
 func SetupConfig() {
-	contents, err := os.ReadFile("/config/config.json")
+	contents, err := os.ReadFile(configPath)
 	if err != nil {
 		if !errors.Is(err, os.ErrNotExist) {
 			panic(fmt.Errorf("failed to read config file: %w", err))
@@ -27,94 +44,226 @@ func SetupConfig() {
 		return
 	}
 
-	var data Config
-	if err = json.Unmarshal(contents, &data); err != nil {
-		slog.Warn("failed to decode config, using default", "err", err)
+	configContents = contents
+
+	data, err := ParseConfig(contents)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "invalid config %s\n%s\n", configPath, err)
+		os.Exit(1)
+	}
+
+	config = data
+}
+
+// ParseConfig decodes and validates a config file.
+func ParseConfig(contents []byte) (Config, error) {
+	data, err := decodeConfig(contents)
+	if err != nil {
+		return Config{}, err
+	}
+
+	if err := data.Validate(); err != nil {
+		return Config{}, err
+	}
+
+	return data, nil
+}
+
+// WatchConfig polls the config file and applies it whenever it changes.
+// Polling the contents rather than watching inodes means the symlink swap
+// kubernetes does when updating a ConfigMap mount is handled for free.
+func WatchConfig(ctx context.Context) {
+	ticker := time.NewTicker(configPollInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			reloadConfig()
+		}
+	}
+}
+
+func reloadConfig() {
+	contents, err := os.ReadFile(configPath)
+	if err != nil {
+		if !errors.Is(err, os.ErrNotExist) {
+			slog.Warn("failed to read config file, keeping current config", "err", err)
+		}
+
+		return
+	}
+
+	data, ok := changedConfig(contents)
+	if !ok {
 		return
 	}
 
+	manager.Lock()
 	config = data
+	manager.Unlock()
+
+	slog.Info("config reloaded")
+	refreshDevices(mqttClient)
 }
 
-var config = Config{
-	Groups: []GroupConfig{},
-	Steps:  5,
+// changedConfig parses contents if they differ from the applied config file.
+// An invalid file is only reported once, not on every poll until it's fixed.
+func changedConfig(contents []byte) (Config, bool) {
+	if bytes.Equal(contents, configContents) {
+		return Config{}, false
+	}
+
+	configContents = contents
+
+	data, err := ParseConfig(contents)
+	if err != nil {
+		slog.Error("config changed but is invalid, keeping current config", "err", err)
+		return Config{}, false
+	}
+
+	return data, true
+}
+
+var config = defaultConfig()
+
+func defaultConfig() Config {
+	return Config{
+		Groups:    []GroupConfig{},
+		Steps:     5,
+		calendars: &calendarCache{},
+	}
 }
 
 type Color string
 
-func (co Color) Evaluate() Oklch {
-	c := string(co)
-	if strings.HasPrefix(c, "#") {
-		switch len(c) {
-		case 7:
-			r := util.Must(strconv.ParseUint(c[1:3], 16, 8))
-			g := util.Must(strconv.ParseUint(c[3:5], 16, 8))
-			b := util.Must(strconv.ParseUint(c[5:7], 16, 8))
+type SelectionStrategy string
+
+const (
+	// random, but never the same color twice in a row
+	SelectUniform SelectionStrategy = "uniform"
+	// random, in proportion to each color's weight
+	SelectWeighted SelectionStrategy = "weighted"
+	// each color in the order listed
+	SelectSequential SelectionStrategy = "sequential"
+	// every color once, in a random order, before any repeat
+	SelectShuffleBag SelectionStrategy = "shuffle_bag"
+	// whichever color looks the most different from the current one
+	SelectMaxDistance SelectionStrategy = "max_distance"
+)
 
-			return OklchFromSRGB(float64(r)/255, float64(g)/255, float64(b)/255)
+var selectionStrategies = []SelectionStrategy{SelectUniform, SelectWeighted, SelectSequential, SelectShuffleBag, SelectMaxDistance}
 
-		case 4:
-			r := util.Must(strconv.ParseUint(string([]byte{c[1]}), 16, 8))
-			g := util.Must(strconv.ParseUint(string([]byte{c[2]}), 16, 8))
-			b := util.Must(strconv.ParseUint(string([]byte{c[3]}), 16, 8))
+type Colors struct {
+	colors   []Oklch
+	weights  []float64      // nil weighs every color the same
+	samplers []func() Oklch // nil entries are fixed colors
+	strategy SelectionStrategy
+
+	selected bool
+	previous int
+	bag      []int
+}
 
-			return OklchFromSRGB(float64(r)/15, float64(g)/15, float64(b)/15)
+// Select picks the next color, given the one the light is at now.
+func (c *Colors) Select(current Oklch) Oklch {
+	util.Assert(len(c.colors) > 0, "must have colors")
 
-		default:
-			panic(fmt.Sprintf("invalid hex color: %s", c))
+	idx := 0
+	switch {
+	case len(c.colors) == 1:
+	case c.strategy == SelectWeighted:
+		idx = c.selectWeighted()
+	case c.strategy == SelectSequential:
+		if c.selected {
+			idx = (c.previous + 1) % len(c.colors)
+		}
+	case c.strategy == SelectShuffleBag:
+		idx = c.selectFromBag()
+	case c.strategy == SelectMaxDistance:
+		idx = c.selectFurthest(current)
+	default:
+		if !c.selected {
+			idx = rand.IntN(len(c.colors))
+			break
+		}
+
+		// pick from every color but the previous one, shifting past it
+		idx = rand.IntN(len(c.colors) - 1)
+		if idx >= c.previous {
+			idx++
 		}
 	}
 
-	if strings.HasPrefix(c, "oklab(") && strings.HasSuffix(c, ")") {
-		params := strings.FieldsFunc(c[6:len(c)-1], func(r rune) bool { return r == ',' || r == ' ' || r == '\t' })
+	c.selected = true
+	c.previous = idx
+
+	if c.samplers != nil && c.samplers[idx] != nil {
+		return c.samplers[idx]()
+	}
 
-		Lpercent := strings.TrimSpace(params[0])
-		util.Assert(strings.HasSuffix(Lpercent, "%"), "invalid oklab format")
+	return c.colors[idx]
+}
 
-		L := util.Must(strconv.ParseFloat(Lpercent[0:len(Lpercent)-1], 64)) / 100
-		A := util.Must(strconv.ParseFloat(strings.TrimSpace(params[1]), 64))
-		B := util.Must(strconv.ParseFloat(strings.TrimSpace(params[2]), 64))
+// Weight is how likely this palette is to be picked next to others.
+func (c *Colors) Weight() float64 {
+	if c.weights == nil {
+		return float64(len(c.colors))
+	}
 
-		H := math.Atan2(B, A) * 180 / math.Pi
-		if H < 0 {
-			H += 360
-		}
-		return Oklch{L: L, C: math.Hypot(A, B), H: H}
+	total := 0.0
+	for _, w := range c.weights {
+		total += w
 	}
 
-	if strings.HasPrefix(c, "oklch(") && strings.HasSuffix(c, ")") {
-		params := strings.FieldsFunc(c[6:len(c)-1], func(r rune) bool { return r == ',' || r == ' ' || r == '\t' })
+	return total
+}
 
-		Lpercent := strings.TrimSpace(params[0])
-		util.Assert(strings.HasSuffix(Lpercent, "%"), "invalid oklab format")
+func (c *Colors) selectWeighted() int {
+	if c.weights == nil {
+		return rand.IntN(len(c.colors))
+	}
 
-		L := util.Must(strconv.ParseFloat(Lpercent[0:len(Lpercent)-1], 64)) / 100
-		C := util.Must(strconv.ParseFloat(strings.TrimSpace(params[1]), 64))
-		H := util.Must(strconv.ParseFloat(strings.TrimSpace(params[2]), 64))
+	n := rand.Float64() * c.Weight()
+	for i, w := range c.weights {
+		if n < w {
+			return i
+		}
 
-		return Oklch{L: L, C: C, H: H}
+		n -= w
 	}
 
-	panic(fmt.Sprintf("invalid color format: %s", c))
+	// rounding can leave n just past the end
+	return len(c.colors) - 1
 }
 
-type Colors struct {
-	colors             []Oklch
-	previouslySelected uint
-}
+func (c *Colors) selectFromBag() int {
+	if len(c.bag) == 0 {
+		c.bag = rand.Perm(len(c.colors))
 
-func (c *Colors) Select() Oklch {
-	util.Assert(len(c.colors) > 0, "must have colors")
+		// don't let a refill start with the color that ended the last bag
+		if c.selected && c.bag[0] == c.previous {
+			last := len(c.bag) - 1
+			c.bag[0], c.bag[last] = c.bag[last], c.bag[0]
+		}
+	}
 
-	idx := rand.UintN(uint(len(c.colors)) - 1)
-	if idx == c.previouslySelected {
-		idx = uint(len(c.colors)) - 1
+	idx := c.bag[0]
+	c.bag = c.bag[1:]
+	return idx
+}
+
+func (c *Colors) selectFurthest(current Oklch) int {
+	best, bestDist := 0, -1.0
+	for i, color := range c.colors {
+		if dist := current.DistanceTo(color); dist > bestDist {
+			best, bestDist = i, dist
+		}
 	}
 
-	col := c.colors[idx]
-	c.previouslySelected = idx
-	return col
+	return best
 }
 
 var loc = mustLoadLocation()
@@ -144,13 +293,31 @@ type TimeConfig struct {
 	FadeOut TimeFader `json:"fade_out"`
 }
 
-func (t TimeConfig) Compile() *TimeOverlay {
-	return &TimeOverlay{
-		fadeInStart:  util.Must(time.ParseInLocation(time.Kitchen, t.FadeIn.Start, loc)),
-		fadeInEnd:    util.Must(time.ParseInLocation(time.Kitchen, t.FadeIn.End, loc)),
-		fadeOutStart: util.Must(time.ParseInLocation(time.Kitchen, t.FadeOut.Start, loc)),
-		fadeOutEnd:   util.Must(time.ParseInLocation(time.Kitchen, t.FadeOut.End, loc)),
+// Compile resolves the fade times. location is only needed when they are
+// relative to solar events.
+func (t TimeConfig) Compile(location *Coordinates) (*TimeOverlay, error) {
+	parse := func(s string) (TimeOfDay, error) {
+		return parseTimeOfDay(s, location)
+	}
+
+	var errs ValidationErrors
+	overlay := &TimeOverlay{
+		fadeInStart:  collect(&errs, "fade_in.start", parse, t.FadeIn.Start),
+		fadeInEnd:    collect(&errs, "fade_in.end", parse, t.FadeIn.End),
+		fadeOutStart: collect(&errs, "fade_out.start", parse, t.FadeOut.Start),
+		fadeOutEnd:   collect(&errs, "fade_out.end", parse, t.FadeOut.End),
+	}
+
+	return overlay, errs.Err()
+}
+
+func parseClock(s string) (time.Time, error) {
+	t, err := time.ParseInLocation(time.Kitchen, s, loc)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("invalid time %q, expected a time like 3:04PM", s)
 	}
+
+	return t, nil
 }
 
 type DateFader struct {
@@ -165,21 +332,169 @@ type SeasonalConfig struct {
 
 var dayLayout = "01-02"
 
-func (s SeasonalConfig) Compile() *DateOverlay {
-	return &DateOverlay{
-		fadeInStart:  util.Must(time.ParseInLocation(dayLayout, s.FadeIn.Start, loc)),
-		fadeInEnd:    util.Must(time.ParseInLocation(dayLayout, s.FadeIn.End, loc)),
-		fadeOutStart: util.Must(time.ParseInLocation(dayLayout, s.FadeOut.Start, loc)),
-		fadeOutEnd:   util.Must(time.ParseInLocation(dayLayout, s.FadeOut.End, loc)),
+func (s SeasonalConfig) Compile() (*DateOverlay, error) {
+	var errs ValidationErrors
+	overlay := &DateOverlay{
+		fadeInStart:  collect(&errs, "fade_in.start", parseDayOfYear, s.FadeIn.Start),
+		fadeInEnd:    collect(&errs, "fade_in.end", parseDayOfYear, s.FadeIn.End),
+		fadeOutStart: collect(&errs, "fade_out.start", parseDayOfYear, s.FadeOut.Start),
+		fadeOutEnd:   collect(&errs, "fade_out.end", parseDayOfYear, s.FadeOut.End),
 	}
+
+	return overlay, errs.Err()
 }
 
-type GroupConfig struct {
-	Colors    []Color  `json:"colors"`
-	AppliesTo []string `json:"applies_to"`
+type WeekdayConfig struct {
+	// weekday names (monday or mon), or weekend/weekdays as shorthand
+	Days []string `json:"days"`
+
+	// how long before an active day starts to begin fading in, and how long
+	// after it ends to finish fading out
+	FadeIn  string `json:"fade_in"`
+	FadeOut string `json:"fade_out"`
+}
+
+func (w WeekdayConfig) Compile() (*WeekdayOverlay, error) {
+	var errs ValidationErrors
+	overlay := &WeekdayOverlay{}
+
+	if len(w.Days) == 0 {
+		errs.Add("days", fmt.Errorf("must have at least one day"))
+	}
+
+	for i, day := range w.Days {
+		switch day = strings.ToLower(day); day {
+		case "weekend":
+			overlay.days[time.Saturday] = true
+			overlay.days[time.Sunday] = true
+		case "weekday", "weekdays":
+			for d := time.Monday; d <= time.Friday; d++ {
+				overlay.days[d] = true
+			}
+		default:
+			d, ok := parseWeekday(day)
+			if !ok {
+				errs.Add(fmt.Sprintf("days[%d]", i), fmt.Errorf("unknown day %q", w.Days[i]))
+				continue
+			}
+
+			overlay.days[d] = true
+		}
+	}
+
+	overlay.fadeIn = collect(&errs, "fade_in", parseFade, w.FadeIn)
+	overlay.fadeOut = collect(&errs, "fade_out", parseFade, w.FadeOut)
+
+	return overlay, errs.Err()
+}
+
+// an optional fade across a day boundary, at most a day long
+func parseFade(s string) (time.Duration, error) {
+	if s == "" {
+		return 0, nil
+	}
+
+	d, err := parseDuration(s)
+	if err != nil {
+		return 0, err
+	}
+
+	if d < 0 || d > 24*time.Hour {
+		return 0, fmt.Errorf("must be between 0 and 24h, got %s", s)
+	}
+
+	return d, nil
+}
+
+type BrightnessConfig struct {
+	// lightness (the default) follows each color's Oklch lightness, random
+	// picks anywhere in the range for every color
+	Mode string `json:"mode"`
+
+	// caps on the zigbee2mqtt brightness, 1 to 254
+	Min int `json:"min"`
+	Max int `json:"max"`
+}
+
+const maxBrightness = 254
+
+// toBrightness converts a 0 to 1 brightness to the zigbee2mqtt scale. 0 is
+// reserved for off, so the dimmest a managed light goes is 1.
+func toBrightness(b float64) int {
+	return min(max(int(math.Round(b*maxBrightness)), 1), maxBrightness)
+}
+
+func (b BrightnessConfig) Compile() (*BrightnessRange, error) {
+	var errs ValidationErrors
+	brightness := &BrightnessRange{min: 1.0 / maxBrightness, max: 1}
+
+	switch b.Mode {
+	case "", "lightness":
+		brightness.mode = BrightnessFromLightness
+	case "random":
+		brightness.mode = BrightnessRandom
+	default:
+		errs.Add("mode", fmt.Errorf("unknown mode %q, expected lightness or random", b.Mode))
+	}
+
+	if b.Min != 0 {
+		if b.Min < 1 || b.Min > maxBrightness {
+			errs.Add("min", fmt.Errorf("must be between 1 and %d, got %d", maxBrightness, b.Min))
+		}
+
+		brightness.min = float64(b.Min) / maxBrightness
+	}
+
+	if b.Max != 0 {
+		if b.Max < 1 || b.Max > maxBrightness {
+			errs.Add("max", fmt.Errorf("must be between 1 and %d, got %d", maxBrightness, b.Max))
+		}
+
+		brightness.max = float64(b.Max) / maxBrightness
+	}
+
+	if brightness.min > brightness.max {
+		errs.Add("", fmt.Errorf("min %d is greater than max %d", b.Min, b.Max))
+	}
 
-	Time *TimeConfig     `json:"time"`
-	Date *SeasonalConfig `json:"date"`
+	return brightness, errs.Err()
+}
+
+// how the lights in the zigbee2mqtt groups a group applies to are driven
+const (
+	// every light picks its own colors and timing
+	ModeIndependent = "independent"
+	// one color and timing for the whole zigbee2mqtt group, sent to the group
+	// topic so the lights stay in lockstep
+	ModeSync = "sync"
+	// lights listed in order share one timeline, each running offset behind
+	// the one before so colors travel along them
+	ModeWave = "wave"
+)
+
+type GroupConfig struct {
+	Name      string            `json:"name"`
+	Colors    []PaletteColor    `json:"colors"`
+	Selection SelectionStrategy `json:"selection"`
+	AppliesTo []string          `json:"applies_to"`
+	Mode      string            `json:"mode"`
+
+	// for waves, device friendly names in the order colors travel along them
+	// and how far behind each runs
+	Order  []string `json:"order"`
+	Offset string   `json:"offset"`
+
+	Time     *TimeConfig     `json:"time"`
+	Date     *SeasonalConfig `json:"date"`
+	Weekdays *WeekdayConfig  `json:"weekdays"`
+	Calendar *CalendarConfig `json:"calendar"`
+
+	Brightness *BrightnessConfig `json:"brightness"`
+
+	// how lights fade into this group's colors. easing also applies to the
+	// brightness fade
+	Interpolation string `json:"interpolation"`
+	Easing        string `json:"easing"`
 }
 
 func (g *GroupConfig) Contains(groups []string) bool {
@@ -196,14 +511,67 @@ func (g *GroupConfig) Contains(groups []string) bool {
 	return false
 }
 
+// DisplayName is the name used to refer to the group in logs and the http api.
+func (g *GroupConfig) DisplayName(index int) string {
+	if g.Name != "" {
+		return g.Name
+	}
+
+	return fmt.Sprintf("group %d", index)
+}
+
 func (g *GroupConfig) IsAmbient() bool {
-	return g.Time == nil && g.Date == nil
+	return g.Time == nil && g.Date == nil && g.Weekdays == nil && g.Calendar == nil
 }
 
-func (g *GroupConfig) CompileColors() []Oklch {
-	colors := make([]Oklch, len(g.Colors))
-	for i, color := range g.Colors {
-		colors[i] = color.Evaluate()
+func (g *GroupConfig) CompilePalette() Palette {
+	palette := Palette{
+		colors: g.CompileColors(),
+	}
+
+	if g.Brightness != nil {
+		palette.brightness = util.Must(g.Brightness.Compile())
+	}
+
+	if g.Interpolation != "" || g.Easing != "" {
+		palette.interpolation = &Interpolation{
+			space: util.Must(parseInterpolationSpace(g.Interpolation)),
+			ease:  util.Must(parseEasing(g.Easing)),
+		}
+	}
+
+	return palette
+}
+
+func (g *GroupConfig) CompileColors() Colors {
+	colors := Colors{strategy: g.Selection}
+
+	sources := []ColorSource{}
+	for _, color := range g.Colors {
+		sources = append(sources, util.Must(color.Compile())...)
+	}
+
+	// every entry weighs what it says, however many colors it expands to, so
+	// uneven weights are asking to be picked by weight
+	even := true
+	for _, source := range sources {
+		colors.colors = append(colors.colors, source.color)
+		colors.weights = append(colors.weights, source.weight)
+		even = even && source.weight == sources[0].weight
+
+		if source.sample != nil && colors.samplers == nil {
+			colors.samplers = make([]func() Oklch, len(sources))
+		}
+	}
+
+	if !even && colors.strategy == "" {
+		colors.strategy = SelectWeighted
+	}
+
+	if colors.samplers != nil {
+		for i, source := range sources {
+			colors.samplers[i] = source.sample
+		}
 	}
 
 	return colors
@@ -219,13 +587,66 @@ type Config struct {
 	Transition Transition `json:"transition"`
 	Hold       Transition `json:"hold"`
 
+	// where the lights are, for overlays relative to sunrise and sunset
+	Location *Coordinates `json:"location"`
+
+	// how long to leave a light alone after someone changes it by hand
+	ManualCooldown string `json:"manual_cooldown"`
+
 	Groups []GroupConfig `json:"groups"`
+
+	// color gamuts for bulb models lumos doesn't already know about
+	Gamuts []GamutConfig `json:"gamuts"`
+
+	// bulb models that reject a transition in their set payload, by zigbee
+	// model id or zigbee2mqtt model. zigbee2mqtt handles it for most lights
+	// without listing it as an option, so it's sent to everything else.
+	NoTransition []string `json:"no_transition"`
+
+	// calendar files the groups use, loaded once for every light
+	calendars *calendarCache
+}
+
+const defaultManualCooldown = 30 * time.Minute
+
+func (c *Config) Cooldown() time.Duration {
+	if c.ManualCooldown == "" {
+		return defaultManualCooldown
+	}
+
+	return util.Must(time.ParseDuration(c.ManualCooldown))
 }
 
 func (c *Config) ContainsGroup(name string) bool {
 	list := []string{name}
 	for _, group := range c.Groups {
-		if group.Contains(list) {
+		if group.Mode != ModeWave && group.Contains(list) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// CompileWave sets up the shared timeline for the wave group at index. It runs
+// the wave's own colors along with any other groups for the same zigbee2mqtt
+// groups.
+func (c *Config) CompileWave(index int) (*Wave, time.Duration) {
+	group := &c.Groups[index]
+	offset := util.Must(parseDuration(group.Offset))
+	lag := offset * time.Duration(max(len(group.Order)-1, 0))
+
+	cfg := c.compile(func(i int, g *GroupConfig) bool {
+		return i == index || (g.Mode != ModeWave && g.Contains(group.AppliesTo))
+	})
+
+	return NewWave(cfg, lag), offset
+}
+
+// SyncsGroup reports whether a zigbee2mqtt group should be driven as one.
+func (c *Config) SyncsGroup(group string) bool {
+	for _, g := range c.Groups {
+		if g.Mode == ModeSync && slices.Contains(g.AppliesTo, group) {
 			return true
 		}
 	}
@@ -233,44 +654,63 @@ func (c *Config) ContainsGroup(name string) bool {
 	return false
 }
 
+// Compile builds the runtime config for a light in the given zigbee2mqtt
+// groups. Waves only drive the lights they list, through CompileWave, so
+// they're left out.
 func (c *Config) Compile(groups []string) RuntimeConfig {
-	ambients := []Oklch{}
+	return c.compile(func(_ int, g *GroupConfig) bool {
+		return g.Mode != ModeWave && g.Contains(groups)
+	})
+}
+
+func (c *Config) compile(include func(int, *GroupConfig) bool) RuntimeConfig {
+	ambients := []Palette{}
 	overlays := []Overlay{}
 
-	for _, group := range c.Groups {
-		if !group.Contains(groups) {
+	for i, group := range c.Groups {
+		if !include(i, &group) {
 			continue
 		}
 
 		if group.IsAmbient() {
-			ambients = append(ambients, group.CompileColors()...)
+			ambients = append(ambients, group.CompilePalette())
 			continue
 		}
 
 		var timeOverlay *TimeOverlay
 		if group.Time != nil {
-			timeOverlay = group.Time.Compile()
+			timeOverlay = util.Must(group.Time.Compile(c.Location))
 		}
 
 		var dateOverlay *DateOverlay
 		if group.Date != nil {
-			dateOverlay = group.Date.Compile()
+			dateOverlay = util.Must(group.Date.Compile())
+		}
+
+		var weekdayOverlay *WeekdayOverlay
+		if group.Weekdays != nil {
+			weekdayOverlay = util.Must(group.Weekdays.Compile())
+		}
+
+		var calendarOverlay *CalendarOverlay
+		if group.Calendar != nil {
+			calendarOverlay = util.Must(group.Calendar.Compile(c.calendars))
 		}
 
 		overlays = append(overlays, Overlay{
-			time: timeOverlay,
-			date: dateOverlay,
-			colors: Colors{
-				colors: group.CompileColors(),
-			},
+			name:     group.DisplayName(i),
+			time:     timeOverlay,
+			date:     dateOverlay,
+			weekday:  weekdayOverlay,
+			calendar: calendarOverlay,
+			palette:  group.CompilePalette(),
 		})
 	}
 
 	return RuntimeConfig{
-		steps: c.Steps,
-		ambients: Colors{
-			colors: ambients,
-		},
+		clock:    realClock,
+		steps:    c.Steps,
+		ambients: ambients,
 		overlays: overlays,
 
 		transitionMin: util.Must(time.ParseDuration(c.Transition.Minimum)),
@@ -280,31 +720,73 @@ func (c *Config) Compile(groups []string) RuntimeConfig {
 	}
 }
 
-func ColorPayload(color Oklch, transition float64) []byte {
-	type Color struct {
-		X float64 `json:"x"`
-		Y float64 `json:"y"`
-	}
+// Command is a message lumos sends to a light's set topic.
+type Command struct {
+	Color      *CommandColor `json:"color,omitempty"`
+	ColorTemp  *int          `json:"color_temp,omitempty"`
+	Brightness *int          `json:"brightness,omitempty"`
+	Transition float64       `json:"transition,omitempty"`
 
-	type Payload struct {
-		Color      Color   `json:"color"`
-		Transition float64 `json:"transition,omitempty"`
+	// the color sent, whichever format it went out in, for recognising it when
+	// the light reports back
+	xy *xyPoint
+}
+
+// CommandColor is either xy or hue and saturation, depending on the bulb.
+type CommandColor struct {
+	X          *float64 `json:"x,omitempty"`
+	Y          *float64 `json:"y,omitempty"`
+	Hue        *float64 `json:"hue,omitempty"`
+	Saturation *float64 `json:"saturation,omitempty"`
+}
+
+func NewCommand(target Target, caps Capabilities, transition float64) Command {
+	cmd := Command{}
+
+	format := caps.Format()
+
+	// only colors need to fit the gamut, white bulbs pick the closest
+	// temperature from the original
+	x, y := target.Color.ToXY()
+	if caps.gamut != nil && (format == FormatXY || format == FormatHS) {
+		target.Color = caps.gamut.Fit(target.Color)
+		x, y = target.Color.rawXY()
 	}
 
-	x, y := color.ToXY()
+	switch format {
+	case FormatXY:
+		cmd.Color = &CommandColor{X: &x, Y: &y}
+		cmd.xy = &xyPoint{X: x, Y: y}
+	case FormatHS:
+		h, s, _ := target.Color.ToHSV()
+		s *= 100
+		cmd.Color = &CommandColor{Hue: &h, Saturation: &s}
+		cmd.xy = &xyPoint{X: x, Y: y}
+	case FormatColorTemp:
+		mireds := caps.colorTemp.Mireds(target.Color)
+		cmd.ColorTemp = &mireds
+	case FormatBrightness:
+		// all a dimmable white bulb can do is follow the lightness
+		if !target.HasBrightness {
+			target.Brightness = target.Color.L
+			target.HasBrightness = true
+		}
+	}
 
-	payload := Payload{
-		Color: Color{
-			X: x,
-			Y: y,
-		},
+	if target.HasBrightness && caps.brightness {
+		brightness := toBrightness(target.Brightness)
+		cmd.Brightness = &brightness
 	}
 
-	if transition > 0 {
-		payload.Transition = transition
+	if transition > 0 && !caps.noTransition {
+		cmd.Transition = transition
 	}
 
-	data, err := json.Marshal(payload)
+	return cmd
+}
+
+func (c Command) Payload() []byte {
+	data, err := json.Marshal(c)
 	if err != nil {
 		panic(fmt.Errorf("failed to marshal color payload: %w", err))
 	}
@@ -312,9 +794,9 @@ func ColorPayload(color Oklch, transition float64) []byte {
 	return data
 }
 
-// minutes since midnight using local clock
+// minutes since midnight on the wall clock of the configured location
 func minutesSinceMidnight(t time.Time) int {
-	t = t.Local()
+	t = t.In(loc)
 	return t.Hour()*60 + t.Minute()
 }
 
@@ -356,12 +838,6 @@ func yearLength(y int) int {
 	return 365
 }
 
-// Parse "MM-DD" and convert to 0-based yearday for given year.
-func ydayFromMMDD(year int, t time.Time) int {
-	tt := time.Date(year, t.Month(), t.Day(), 0, 0, 0, 0, loc)
-	return tt.YearDay() - 1 // 0-based
-}
-
 // Is x on the forward arc a→b (inclusive) on a 0..total-1 ring.
 func inArcDays(a, b, x, total int) bool {
 	return distFwdDays(a, x, total) <= distFwdDays(a, b, total)
@@ -0,0 +1,48 @@
+package main
+
+import (
+	"fmt"
+	"testing"
+)
+
+func TestChangedConfig(t *testing.T) {
+	previous := configContents
+	t.Cleanup(func() { configContents = previous })
+	configContents = nil
+
+	file := func(steps int) []byte {
+		return fmt.Appendf(nil, `{
+			"steps": %d,
+			"transition": {"min": "1s", "max": "2s"},
+			"hold": {"min": "0s", "max": "1s"},
+			"groups": [{"colors": ["#ff0000"]}]
+		}`, steps)
+	}
+
+	valid, edited, invalid := file(5), file(8), file(0)
+
+	cases := []struct {
+		name     string
+		contents []byte
+		applied  bool
+		steps    uint
+	}{
+		{"first load", valid, true, 5},
+		{"unchanged", valid, false, 0},
+		{"edited", edited, true, 8},
+		{"broken", invalid, false, 0},
+		{"still broken", invalid, false, 0},
+		{"fixed", edited, true, 8},
+	}
+
+	for _, c := range cases {
+		data, ok := changedConfig(c.contents)
+		if ok != c.applied {
+			t.Fatalf("%s: got applied %t want %t", c.name, ok, c.applied)
+		}
+
+		if ok && data.Steps != c.steps {
+			t.Fatalf("%s: got %d steps want %d", c.name, data.Steps, c.steps)
+		}
+	}
+}
@@ -0,0 +1,224 @@
+package main
+
+import (
+	"log/slog"
+	"strings"
+	"sync"
+	"time"
+
+	mqtt "github.com/eclipse/paho.mqtt.golang"
+)
+
+const controlPrefix = "lumos/"
+
+// LightState tracks whether lumos is allowed to drive a single light. It lives
+// on the manager rather than the ColorManager so that a pause survives a config
+// refresh restarting the light's goroutine.
+type LightState struct {
+	mu sync.Mutex
+
+	paused bool
+	timer  *time.Timer
+	until  time.Time
+
+	// the synced group this light is a member of, which holds off publishing
+	// while the light isn't active
+	group *LightState
+
+	// what lumos last sent and what the device last reported
+	published           []xyPoint
+	publishedBrightness []int
+	publishedMireds     []int
+	power               string
+	brightness          *int
+
+	// closed and replaced whenever the state changes, so a ColorManager can
+	// select on it to wake up
+	changed chan struct{}
+}
+
+func NewLightState() *LightState {
+	return &LightState{
+		changed: make(chan struct{}),
+	}
+}
+
+// Pause stops lumos from driving the light. A duration of 0 pauses until an
+// explicit resume, anything else resumes automatically once it elapses.
+func (s *LightState) Pause(d time.Duration) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.pause(d)
+}
+
+// must be called with the lock held
+func (s *LightState) pause(d time.Duration) {
+	if s.timer != nil {
+		s.timer.Stop()
+		s.timer = nil
+	}
+
+	s.until = time.Time{}
+	if d > 0 {
+		s.timer = time.AfterFunc(d, s.Resume)
+		s.until = time.Now().Add(d)
+	}
+
+	s.paused = true
+	s.notify()
+}
+
+func (s *LightState) Resume() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.timer != nil {
+		s.timer.Stop()
+		s.timer = nil
+	}
+
+	if !s.paused {
+		return
+	}
+
+	s.until = time.Time{}
+	s.paused = false
+	s.notify()
+}
+
+// Active reports whether lumos should be publishing to the light: it isn't
+// paused and isn't known to be switched off.
+func (s *LightState) Active() bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.active()
+}
+
+// must be called with the lock held
+func (s *LightState) active() bool {
+	return !s.paused && s.power != "OFF"
+}
+
+func (s *LightState) SetGroup(group *LightState) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.group = group
+}
+
+// Changed returns a channel that is closed the next time the state changes.
+// Grab it before checking Active to avoid missing an update.
+func (s *LightState) Changed() <-chan struct{} {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.changed
+}
+
+// must be called with the lock held
+func (s *LightState) notify() {
+	close(s.changed)
+	s.changed = make(chan struct{})
+
+	// wake the group's ColorManager so it rechecks its members
+	if s.group != nil {
+		s.group.mu.Lock()
+		s.group.notify()
+		s.group.mu.Unlock()
+	}
+}
+
+func setupControl(client mqtt.Client) {
+	subscribe(client, controlPrefix+"#", 1, onControl)
+}
+
+// onControl handles the lumos command topic tree:
+//
+//	lumos/all/<action>
+//	lumos/group/<group friendly name>/<action>
+//	lumos/<device friendly name>/<action>
+//
+// where action is pause or resume. A pause payload may contain a duration
+// (e.g. "30m") after which the light resumes on its own.
+func onControl(c mqtt.Client, m mqtt.Message) {
+	path := strings.TrimPrefix(m.Topic(), controlPrefix)
+
+	idx := strings.LastIndex(path, "/")
+	if idx <= 0 {
+		slog.Warn("invalid control topic", "topic", m.Topic())
+		return
+	}
+
+	target, action := path[:idx], path[idx+1:]
+
+	var apply func(*LightState)
+	switch action {
+	case "pause":
+		var duration time.Duration
+		if payload := strings.TrimSpace(string(m.Payload())); payload != "" {
+			d, err := time.ParseDuration(payload)
+			if err != nil {
+				slog.Warn("invalid pause duration", "topic", m.Topic(), "payload", payload, "err", err)
+				return
+			}
+
+			duration = d
+		}
+
+		apply = func(s *LightState) { s.Pause(duration) }
+	case "resume":
+		apply = func(s *LightState) { s.Resume() }
+	default:
+		slog.Warn("unknown control action", "topic", m.Topic(), "action", action)
+		return
+	}
+
+	manager.Lock()
+	defer manager.Unlock()
+
+	var names []string
+	switch {
+	case target == "all":
+		names = manager.LightNames()
+	case strings.HasPrefix(target, "group/"):
+		group := strings.TrimPrefix(target, "group/")
+		names = groupDeviceNames(group)
+
+		// synced groups are driven through their own state
+		if _, ok := manager.lights[group]; ok {
+			names = append(names, group)
+		}
+	default:
+		names = []string{target}
+	}
+
+	// only lights lumos drives, so a typo doesn't conjure up a new one
+	applied := 0
+	for _, name := range names {
+		if state, ok := manager.lights[name]; ok {
+			apply(state)
+			applied++
+		}
+	}
+
+	if applied == 0 {
+		slog.Warn("ignoring control command for unknown lights", "topic", m.Topic(), "target", target)
+		return
+	}
+
+	slog.Info("control command applied", "action", action, "target", target, "lights", applied)
+}
+
+// friendly names of every known device in a zigbee2mqtt group
+func groupDeviceNames(group string) []string {
+	names := []string{}
+	for _, ieee := range groupMembers[group] {
+		if device, ok := devices[ieee]; ok {
+			names = append(names, device.FriendlyName)
+		}
+	}
+
+	return names
+}
@@ -0,0 +1,141 @@
+package main
+
+import (
+	"testing"
+	"time"
+
+	mqtt "github.com/eclipse/paho.mqtt.golang"
+)
+
+// closed reports whether a Changed channel has fired.
+func closed(c <-chan struct{}) bool {
+	select {
+	case <-c:
+		return true
+	default:
+		return false
+	}
+}
+
+func TestLightStatePauseResume(t *testing.T) {
+	state := NewLightState()
+	if !state.Active() {
+		t.Fatal("expected a new light to be active")
+	}
+
+	changed := state.Changed()
+	state.Pause(0)
+	if state.Active() {
+		t.Fatal("expected paused light to be inactive")
+	}
+
+	if !closed(changed) {
+		t.Fatal("expected pause to close the changed channel")
+	}
+
+	var status LightStatus
+	state.fillStatus(&status)
+	if !status.Paused || status.PausedUntil != nil {
+		t.Fatalf("expected an indefinite pause, got %+v", status)
+	}
+
+	changed = state.Changed()
+	if closed(changed) {
+		t.Fatal("expected a fresh changed channel after notifying")
+	}
+
+	state.Resume()
+	if !state.Active() || !closed(changed) {
+		t.Fatal("expected resume to reactivate the light and notify")
+	}
+
+	// resuming a light that isn't paused isn't a change
+	changed = state.Changed()
+	state.Resume()
+	if closed(changed) {
+		t.Fatal("expected resuming an active light not to notify")
+	}
+}
+
+func TestLightStatePauseExpires(t *testing.T) {
+	state := NewLightState()
+
+	state.Pause(time.Hour)
+
+	var status LightStatus
+	state.fillStatus(&status)
+	if status.PausedUntil == nil {
+		t.Fatal("expected a timed pause")
+	}
+
+	// pausing again replaces the timer
+	state.Pause(10 * time.Millisecond)
+	changed := state.Changed()
+
+	select {
+	case <-changed:
+	case <-time.After(time.Second):
+		t.Fatal("expected the pause to expire")
+	}
+
+	if !state.Active() {
+		t.Fatal("expected the light to resume on its own")
+	}
+}
+
+func TestLightStatePauseReplacesTimer(t *testing.T) {
+	state := NewLightState()
+
+	// an indefinite pause overrides a timed one, so it must not expire
+	state.Pause(10 * time.Millisecond)
+	state.Pause(0)
+
+	time.Sleep(30 * time.Millisecond)
+	if state.Active() {
+		t.Fatal("expected the earlier timer to be cancelled")
+	}
+}
+
+// fakeMessage is an incoming mqtt message with just a topic and payload.
+type fakeMessage struct {
+	mqtt.Message
+	topic   string
+	payload string
+}
+
+func (m fakeMessage) Topic() string   { return m.topic }
+func (m fakeMessage) Payload() []byte { return []byte(m.payload) }
+
+func TestControlIgnoresUnknownLights(t *testing.T) {
+	manager.Lock()
+	previous, previousMembers, previousDevices := manager.lights, groupMembers, devices
+	desk := NewLightState()
+	manager.lights = map[string]*LightState{"desk": desk}
+	groupMembers = map[string][]string{"office": {"0x1", "0x2"}}
+	devices = map[string]Z2MDevice{
+		"0x1": {FriendlyName: "desk", IeeeAddress: "0x1"},
+		"0x2": {FriendlyName: "plug", IeeeAddress: "0x2"},
+	}
+	manager.Unlock()
+
+	t.Cleanup(func() {
+		manager.Lock()
+		manager.lights, groupMembers, devices = previous, previousMembers, previousDevices
+		manager.Unlock()
+	})
+
+	onControl(nil, fakeMessage{topic: "lumos/dsek/pause"})
+	onControl(nil, fakeMessage{topic: "lumos/group/office/pause"})
+
+	manager.Lock()
+	names := manager.LightNames()
+	manager.Unlock()
+
+	if len(names) != 1 || names[0] != "desk" {
+		t.Fatalf("expected only the desk to be known, got %v", names)
+	}
+
+	if desk.Active() {
+		t.Fatal("expected the group pause to reach the desk")
+	}
+}
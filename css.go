@@ -0,0 +1,644 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// Evaluate parses a css color. Supported are hex colors, named colors and the
+// rgb(), hsl(), hwb(), lab(), lch(), oklab(), oklch() and color() functions.
+// Lights have no alpha, so any alpha given is checked and then ignored.
+//
+// On top of css, white can be given as a color temperature like 2700K or
+// 370mired, and any chromaticity as xy(0.45, 0.41).
+func (co Color) Evaluate() (Oklch, error) {
+	c := strings.ToLower(strings.TrimSpace(string(co)))
+
+	if strings.HasPrefix(c, "#") {
+		return parseHexColor(c)
+	}
+
+	if m := temperaturePattern.FindStringSubmatch(c); m != nil {
+		return parseTemperature(c, m[1], m[2])
+	}
+
+	if hex, ok := namedColors[c]; ok {
+		return parseHexColor(hex)
+	}
+
+	if c == "transparent" || c == "currentcolor" {
+		return Oklch{}, fmt.Errorf("%q doesn't name a color a light can show", c)
+	}
+
+	name, params, ok := colorFunction(c)
+	if !ok {
+		return Oklch{}, fmt.Errorf("unknown color format %q", c)
+	}
+
+	parse, ok := colorFunctions[name]
+	if !ok {
+		return Oklch{}, fmt.Errorf("unknown color function %s()", name)
+	}
+
+	color, err := parse(params)
+	if err != nil {
+		return Oklch{}, fmt.Errorf("invalid %s(): %w", name, err)
+	}
+
+	return color, nil
+}
+
+var colorFunctions = map[string]func([]string) (Oklch, error){
+	"rgb":   parseRGB,
+	"rgba":  parseRGB,
+	"hsl":   parseHSL,
+	"hsla":  parseHSL,
+	"hwb":   parseHWB,
+	"lab":   parseLab,
+	"lch":   parseLCH,
+	"oklab": parseOklab,
+	"oklch": parseOklch,
+	"color": parseColorSpace,
+	"xy":    parseXY,
+}
+
+func parseHexColor(c string) (Oklch, error) {
+	digits := c[1:]
+
+	var scale float64
+	var parts []string
+	switch len(digits) {
+	case 3, 4:
+		scale = 15
+		parts = []string{digits[0:1], digits[1:2], digits[2:3]}
+	case 6, 8:
+		scale = 255
+		parts = []string{digits[0:2], digits[2:4], digits[4:6]}
+	default:
+		return Oklch{}, fmt.Errorf("invalid hex color %q, expected #rgb, #rgba, #rrggbb or #rrggbbaa", c)
+	}
+
+	if _, err := strconv.ParseUint(digits, 16, 32); err != nil {
+		return Oklch{}, fmt.Errorf("invalid hex color %q", c)
+	}
+
+	var rgb [3]float64
+	for i, part := range parts {
+		v, _ := strconv.ParseUint(part, 16, 8)
+		rgb[i] = float64(v) / scale
+	}
+
+	return OklchFromSRGB(rgb[0], rgb[1], rgb[2]), nil
+}
+
+// colorFunction splits a css style function like "oklch(50% 0.1 200 / 0.5)"
+// into its name and parameters, dropping the alpha. Commas are accepted in
+// place of spaces for the legacy syntax.
+func colorFunction(c string) (string, []string, bool) {
+	open := strings.Index(c, "(")
+	if open <= 0 || !strings.HasSuffix(c, ")") {
+		return "", nil, false
+	}
+
+	name := strings.TrimSpace(c[:open])
+	body := strings.ReplaceAll(c[open+1:len(c)-1], "/", " / ")
+	params := strings.FieldsFunc(body, func(r rune) bool { return r == ',' || r == ' ' || r == '\t' })
+
+	return name, params, true
+}
+
+// splitAlpha checks for n components plus an optional "/ alpha" or legacy
+// fourth component, and returns just the components.
+func splitAlpha(params []string, n int) ([]string, error) {
+	alpha := ""
+	for i, param := range params {
+		if param == "/" {
+			if i != len(params)-2 {
+				return nil, errors.New("expected a single alpha value after /")
+			}
+
+			alpha = params[i+1]
+			params = params[:i]
+			break
+		}
+	}
+
+	if alpha == "" && len(params) == n+1 {
+		alpha = params[n]
+		params = params[:n]
+	}
+
+	if len(params) != n {
+		return nil, fmt.Errorf("expected %d components, got %d", n, len(params))
+	}
+
+	if alpha != "" {
+		if _, err := cssNumber(alpha, 1); err != nil {
+			return nil, fmt.Errorf("alpha: %w", err)
+		}
+	}
+
+	return params, nil
+}
+
+// cssNumber parses a number or a percentage, where 100% is full. none counts
+// as 0.
+func cssNumber(s string, full float64) (float64, error) {
+	if s == "none" {
+		return 0, nil
+	}
+
+	if strings.HasSuffix(s, "%") {
+		v, err := strconv.ParseFloat(s[:len(s)-1], 64)
+		if err != nil {
+			return 0, fmt.Errorf("invalid percentage %q", s)
+		}
+
+		return v / 100 * full, nil
+	}
+
+	v, err := strconv.ParseFloat(s, 64)
+	if err != nil {
+		return 0, fmt.Errorf("invalid number %q", s)
+	}
+
+	return v, nil
+}
+
+// cssHue parses an angle into degrees. Unitless numbers are degrees.
+func cssHue(s string) (float64, error) {
+	if s == "none" {
+		return 0, nil
+	}
+
+	units := []struct {
+		suffix string
+		scale  float64
+	}{
+		{"deg", 1},
+		{"grad", 0.9},
+		{"rad", 180 / math.Pi},
+		{"turn", 360},
+	}
+
+	scale := 1.0
+	for _, unit := range units {
+		if strings.HasSuffix(s, unit.suffix) {
+			s, scale = strings.TrimSuffix(s, unit.suffix), unit.scale
+			break
+		}
+	}
+
+	v, err := strconv.ParseFloat(s, 64)
+	if err != nil {
+		return 0, fmt.Errorf("invalid hue %q", s)
+	}
+
+	return math.Mod(math.Mod(v*scale, 360)+360, 360), nil
+}
+
+// components parses each parameter with its own parser, naming the component
+// in errors.
+func components(params []string, names []string, parse []func(string) (float64, error)) ([]float64, error) {
+	values := make([]float64, len(params))
+	for i, param := range params {
+		v, err := parse[i](param)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", names[i], err)
+		}
+
+		values[i] = v
+	}
+
+	return values, nil
+}
+
+func number(full float64) func(string) (float64, error) {
+	return func(s string) (float64, error) { return cssNumber(s, full) }
+}
+
+func parseRGB(params []string) (Oklch, error) {
+	params, err := splitAlpha(params, 3)
+	if err != nil {
+		return Oklch{}, err
+	}
+
+	rgb, err := components(params, []string{"red", "green", "blue"}, []func(string) (float64, error){number(255), number(255), number(255)})
+	if err != nil {
+		return Oklch{}, err
+	}
+
+	return OklchFromSRGB(clamp01(rgb[0]/255), clamp01(rgb[1]/255), clamp01(rgb[2]/255)), nil
+}
+
+func parseHSL(params []string) (Oklch, error) {
+	params, err := splitAlpha(params, 3)
+	if err != nil {
+		return Oklch{}, err
+	}
+
+	hsl, err := components(params, []string{"hue", "saturation", "lightness"}, []func(string) (float64, error){cssHue, number(100), number(100)})
+	if err != nil {
+		return Oklch{}, err
+	}
+
+	r, g, b := hslToSRGB(hsl[0], clamp01(hsl[1]/100), clamp01(hsl[2]/100))
+	return OklchFromSRGB(r, g, b), nil
+}
+
+func hslToSRGB(h, s, l float64) (r, g, b float64) {
+	f := func(n float64) float64 {
+		k := math.Mod(n+h/30, 12)
+		a := s * min(l, 1-l)
+		return l - a*max(-1, min(k-3, 9-k, 1))
+	}
+
+	return f(0), f(8), f(4)
+}
+
+func parseHWB(params []string) (Oklch, error) {
+	params, err := splitAlpha(params, 3)
+	if err != nil {
+		return Oklch{}, err
+	}
+
+	hwb, err := components(params, []string{"hue", "whiteness", "blackness"}, []func(string) (float64, error){cssHue, number(100), number(100)})
+	if err != nil {
+		return Oklch{}, err
+	}
+
+	white, black := clamp01(hwb[1]/100), clamp01(hwb[2]/100)
+	if white+black >= 1 {
+		gray := white / (white + black)
+		return OklchFromSRGB(gray, gray, gray), nil
+	}
+
+	r, g, b := hslToSRGB(hwb[0], 1, 0.5)
+	scale := 1 - white - black
+	return OklchFromSRGB(r*scale+white, g*scale+white, b*scale+white), nil
+}
+
+func parseLab(params []string) (Oklch, error) {
+	params, err := splitAlpha(params, 3)
+	if err != nil {
+		return Oklch{}, err
+	}
+
+	lab, err := components(params, []string{"lightness", "a", "b"}, []func(string) (float64, error){number(100), number(125), number(125)})
+	if err != nil {
+		return Oklch{}, err
+	}
+
+	return oklchFromLab(min(max(lab[0], 0), 100), lab[1], lab[2]), nil
+}
+
+func parseLCH(params []string) (Oklch, error) {
+	params, err := splitAlpha(params, 3)
+	if err != nil {
+		return Oklch{}, err
+	}
+
+	lch, err := components(params, []string{"lightness", "chroma", "hue"}, []func(string) (float64, error){number(100), number(150), cssHue})
+	if err != nil {
+		return Oklch{}, err
+	}
+
+	h := lch[2] * math.Pi / 180
+	chroma := max(lch[1], 0)
+	return oklchFromLab(min(max(lch[0], 0), 100), chroma*math.Cos(h), chroma*math.Sin(h)), nil
+}
+
+func parseOklab(params []string) (Oklch, error) {
+	params, err := splitAlpha(params, 3)
+	if err != nil {
+		return Oklch{}, err
+	}
+
+	lab, err := components(params, []string{"lightness", "a", "b"}, []func(string) (float64, error){number(1), number(0.4), number(0.4)})
+	if err != nil {
+		return Oklch{}, err
+	}
+
+	h := math.Atan2(lab[2], lab[1]) * 180 / math.Pi
+	if h < 0 {
+		h += 360
+	}
+
+	return Oklch{L: clamp01(lab[0]), C: math.Hypot(lab[1], lab[2]), H: h}, nil
+}
+
+func parseOklch(params []string) (Oklch, error) {
+	params, err := splitAlpha(params, 3)
+	if err != nil {
+		return Oklch{}, err
+	}
+
+	lch, err := components(params, []string{"lightness", "chroma", "hue"}, []func(string) (float64, error){number(1), number(0.4), cssHue})
+	if err != nil {
+		return Oklch{}, err
+	}
+
+	return Oklch{L: clamp01(lch[0]), C: max(lch[1], 0), H: lch[2]}, nil
+}
+
+// color spaces color() understands, each converting its components to linear
+// sRGB
+var colorSpaces = map[string]func(a, b, c float64) (float64, float64, float64){
+	"srgb": func(r, g, b float64) (float64, float64, float64) {
+		return srgbToLinear(r), srgbToLinear(g), srgbToLinear(b)
+	},
+	"srgb-linear": func(r, g, b float64) (float64, float64, float64) {
+		return r, g, b
+	},
+	"display-p3": func(r, g, b float64) (float64, float64, float64) {
+		r, g, b = srgbToLinear(r), srgbToLinear(g), srgbToLinear(b)
+		return xyzD65ToLinearSRGB(
+			0.4865709486*r+0.2656676932*g+0.1982172852*b,
+			0.2289745641*r+0.6917385218*g+0.0792869141*b,
+			0.0000000000*r+0.0451133819*g+1.0439443689*b,
+		)
+	},
+	"xyz":     xyzD65ToLinearSRGB,
+	"xyz-d65": xyzD65ToLinearSRGB,
+	"xyz-d50": func(x, y, z float64) (float64, float64, float64) {
+		return xyzD65ToLinearSRGB(d50ToD65(x, y, z))
+	},
+}
+
+func parseColorSpace(params []string) (Oklch, error) {
+	if len(params) == 0 {
+		return Oklch{}, errors.New("expected a color space")
+	}
+
+	convert, ok := colorSpaces[params[0]]
+	if !ok {
+		return Oklch{}, fmt.Errorf("unsupported color space %q, expected srgb, srgb-linear, display-p3, xyz-d65 or xyz-d50", params[0])
+	}
+
+	params, err := splitAlpha(params[1:], 3)
+	if err != nil {
+		return Oklch{}, err
+	}
+
+	values, err := components(params, []string{"first component", "second component", "third component"}, []func(string) (float64, error){number(1), number(1), number(1)})
+	if err != nil {
+		return Oklch{}, err
+	}
+
+	return OklchFromLinearSRGB(convert(values[0], values[1], values[2])), nil
+}
+
+var temperaturePattern = regexp.MustCompile(`^([0-9.]+)\s*(k|mireds?)$`)
+
+// the range planckianXY is accurate over
+const (
+	minKelvin = 1667
+	maxKelvin = 25000
+)
+
+func parseTemperature(c, value, unit string) (Oklch, error) {
+	v, err := strconv.ParseFloat(value, 64)
+	if err != nil || v <= 0 {
+		return Oklch{}, fmt.Errorf("invalid color temperature %q", c)
+	}
+
+	kelvin := v
+	if unit != "k" {
+		kelvin = 1e6 / v
+	}
+
+	if kelvin < minKelvin || kelvin > maxKelvin {
+		return Oklch{}, fmt.Errorf("color temperature %q must be between %dK and %dK (%d to %d mireds)", c, minKelvin, maxKelvin, 1_000_000/maxKelvin, 1_000_000/minKelvin)
+	}
+
+	return oklchFromXY(planckianXY(kelvin)), nil
+}
+
+func parseXY(params []string) (Oklch, error) {
+	if len(params) != 2 {
+		return Oklch{}, fmt.Errorf("expected x and y, got %d components", len(params))
+	}
+
+	xy, err := components(params, []string{"x", "y"}, []func(string) (float64, error){number(1), number(1)})
+	if err != nil {
+		return Oklch{}, err
+	}
+
+	x, y := xy[0], xy[1]
+	if x < 0 || y <= 0 || x+y > 1 {
+		return Oklch{}, fmt.Errorf("(%g, %g) isn't a valid chromaticity", x, y)
+	}
+
+	return oklchFromXY(x, y), nil
+}
+
+// oklchFromXY gives a chromaticity the brightest lightness sRGB can show it
+// at, which is how a bulb asked for that xy at full brightness looks.
+func oklchFromXY(x, y float64) Oklch {
+	r, g, b := xyzD65ToLinearSRGB(x/y, 1, (1-x-y)/y)
+
+	if brightest := max(r, g, b); brightest > 0 {
+		r, g, b = r/brightest, g/brightest, b/brightest
+	}
+
+	return OklchFromLinearSRGB(r, g, b)
+}
+
+// cie lab is relative to a d50 white
+func oklchFromLab(l, a, b float64) Oklch {
+	const (
+		kappa   = 24389.0 / 27
+		epsilon = 216.0 / 24389
+	)
+
+	fy := (l + 16) / 116
+	fx := a/500 + fy
+	fz := fy - b/200
+
+	inverse := func(f float64) float64 {
+		if f*f*f > epsilon {
+			return f * f * f
+		}
+
+		return (116*f - 16) / kappa
+	}
+
+	y := l / kappa
+	if l > kappa*epsilon {
+		y = fy * fy * fy
+	}
+
+	x := inverse(fx) * 0.3457 / 0.3585
+	z := inverse(fz) * (1 - 0.3457 - 0.3585) / 0.3585
+
+	return OklchFromLinearSRGB(xyzD65ToLinearSRGB(d50ToD65(x, y, z)))
+}
+
+// bradford chromatic adaptation from a d50 to a d65 white
+func d50ToD65(x, y, z float64) (float64, float64, float64) {
+	return 0.9554734527042182*x - 0.023098536874261423*y + 0.0632593086610217*z,
+		-0.028369706963208136*x + 1.0099954580058226*y + 0.021041398966943008*z,
+		0.012314001688319899*x - 0.020507696433477912*y + 1.3303659366080753*z
+}
+
+func xyzD65ToLinearSRGB(x, y, z float64) (float64, float64, float64) {
+	return 3.2409699419*x - 1.5373831776*y - 0.4986107603*z,
+		-0.9692436363*x + 1.8759675015*y + 0.0415550574*z,
+		0.0556300797*x - 0.2039769589*y + 1.0569715142*z
+}
+
+// the css named colors
+var namedColors = map[string]string{
+	"aliceblue":            "#f0f8ff",
+	"antiquewhite":         "#faebd7",
+	"aqua":                 "#00ffff",
+	"aquamarine":           "#7fffd4",
+	"azure":                "#f0ffff",
+	"beige":                "#f5f5dc",
+	"bisque":               "#ffe4c4",
+	"black":                "#000000",
+	"blanchedalmond":       "#ffebcd",
+	"blue":                 "#0000ff",
+	"blueviolet":           "#8a2be2",
+	"brown":                "#a52a2a",
+	"burlywood":            "#deb887",
+	"cadetblue":            "#5f9ea0",
+	"chartreuse":           "#7fff00",
+	"chocolate":            "#d2691e",
+	"coral":                "#ff7f50",
+	"cornflowerblue":       "#6495ed",
+	"cornsilk":             "#fff8dc",
+	"crimson":              "#dc143c",
+	"cyan":                 "#00ffff",
+	"darkblue":             "#00008b",
+	"darkcyan":             "#008b8b",
+	"darkgoldenrod":        "#b8860b",
+	"darkgray":             "#a9a9a9",
+	"darkgreen":            "#006400",
+	"darkgrey":             "#a9a9a9",
+	"darkkhaki":            "#bdb76b",
+	"darkmagenta":          "#8b008b",
+	"darkolivegreen":       "#556b2f",
+	"darkorange":           "#ff8c00",
+	"darkorchid":           "#9932cc",
+	"darkred":              "#8b0000",
+	"darksalmon":           "#e9967a",
+	"darkseagreen":         "#8fbc8f",
+	"darkslateblue":        "#483d8b",
+	"darkslategray":        "#2f4f4f",
+	"darkslategrey":        "#2f4f4f",
+	"darkturquoise":        "#00ced1",
+	"darkviolet":           "#9400d3",
+	"deeppink":             "#ff1493",
+	"deepskyblue":          "#00bfff",
+	"dimgray":              "#696969",
+	"dimgrey":              "#696969",
+	"dodgerblue":           "#1e90ff",
+	"firebrick":            "#b22222",
+	"floralwhite":          "#fffaf0",
+	"forestgreen":          "#228b22",
+	"fuchsia":              "#ff00ff",
+	"gainsboro":            "#dcdcdc",
+	"ghostwhite":           "#f8f8ff",
+	"gold":                 "#ffd700",
+	"goldenrod":            "#daa520",
+	"gray":                 "#808080",
+	"green":                "#008000",
+	"greenyellow":          "#adff2f",
+	"grey":                 "#808080",
+	"honeydew":             "#f0fff0",
+	"hotpink":              "#ff69b4",
+	"indianred":            "#cd5c5c",
+	"indigo":               "#4b0082",
+	"ivory":                "#fffff0",
+	"khaki":                "#f0e68c",
+	"lavender":             "#e6e6fa",
+	"lavenderblush":        "#fff0f5",
+	"lawngreen":            "#7cfc00",
+	"lemonchiffon":         "#fffacd",
+	"lightblue":            "#add8e6",
+	"lightcoral":           "#f08080",
+	"lightcyan":            "#e0ffff",
+	"lightgoldenrodyellow": "#fafad2",
+	"lightgray":            "#d3d3d3",
+	"lightgreen":           "#90ee90",
+	"lightgrey":            "#d3d3d3",
+	"lightpink":            "#ffb6c1",
+	"lightsalmon":          "#ffa07a",
+	"lightseagreen":        "#20b2aa",
+	"lightskyblue":         "#87cefa",
+	"lightslategray":       "#778899",
+	"lightslategrey":       "#778899",
+	"lightsteelblue":       "#b0c4de",
+	"lightyellow":          "#ffffe0",
+	"lime":                 "#00ff00",
+	"limegreen":            "#32cd32",
+	"linen":                "#faf0e6",
+	"magenta":              "#ff00ff",
+	"maroon":               "#800000",
+	"mediumaquamarine":     "#66cdaa",
+	"mediumblue":           "#0000cd",
+	"mediumorchid":         "#ba55d3",
+	"mediumpurple":         "#9370db",
+	"mediumseagreen":       "#3cb371",
+	"mediumslateblue":      "#7b68ee",
+	"mediumspringgreen":    "#00fa9a",
+	"mediumturquoise":      "#48d1cc",
+	"mediumvioletred":      "#c71585",
+	"midnightblue":         "#191970",
+	"mintcream":            "#f5fffa",
+	"mistyrose":            "#ffe4e1",
+	"moccasin":             "#ffe4b5",
+	"navajowhite":          "#ffdead",
+	"navy":                 "#000080",
+	"oldlace":              "#fdf5e6",
+	"olive":                "#808000",
+	"olivedrab":            "#6b8e23",
+	"orange":               "#ffa500",
+	"orangered":            "#ff4500",
+	"orchid":               "#da70d6",
+	"palegoldenrod":        "#eee8aa",
+	"palegreen":            "#98fb98",
+	"paleturquoise":        "#afeeee",
+	"palevioletred":        "#db7093",
+	"papayawhip":           "#ffefd5",
+	"peachpuff":            "#ffdab9",
+	"peru":                 "#cd853f",
+	"pink":                 "#ffc0cb",
+	"plum":                 "#dda0dd",
+	"powderblue":           "#b0e0e6",
+	"purple":               "#800080",
+	"rebeccapurple":        "#663399",
+	"red":                  "#ff0000",
+	"rosybrown":            "#bc8f8f",
+	"royalblue":            "#4169e1",
+	"saddlebrown":          "#8b4513",
+	"salmon":               "#fa8072",
+	"sandybrown":           "#f4a460",
+	"seagreen":             "#2e8b57",
+	"seashell":             "#fff5ee",
+	"sienna":               "#a0522d",
+	"silver":               "#c0c0c0",
+	"skyblue":              "#87ceeb",
+	"slateblue":            "#6a5acd",
+	"slategray":            "#708090",
+	"slategrey":            "#708090",
+	"snow":                 "#fffafa",
+	"springgreen":          "#00ff7f",
+	"steelblue":            "#4682b4",
+	"tan":                  "#d2b48c",
+	"teal":                 "#008080",
+	"thistle":              "#d8bfd8",
+	"tomato":               "#ff6347",
+	"turquoise":            "#40e0d0",
+	"violet":               "#ee82ee",
+	"wheat":                "#f5deb3",
+	"white":                "#ffffff",
+	"whitesmoke":           "#f5f5f5",
+	"yellow":               "#ffff00",
+	"yellowgreen":          "#9acd32",
+}
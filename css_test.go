@@ -0,0 +1,108 @@
+package main
+
+import (
+	"math"
+	"testing"
+)
+
+func TestEvaluateCssColors(t *testing.T) {
+	red := OklchFromSRGB(1, 0, 0)
+	lime := OklchFromSRGB(0, 1, 0)
+	purple := OklchFromSRGB(0x66/255.0, 0x33/255.0, 0x99/255.0)
+
+	cases := []struct {
+		input string
+		want  Oklch
+	}{
+		{"#ff000080", red},
+		{"#f008", red},
+		{"Red", red},
+		{"rebeccapurple", purple},
+		{"rgb(255 0 0)", red},
+		{"rgba(255, 0, 0, 0.5)", red},
+		{"rgb(100% 0% 0% / 50%)", red},
+		{"hsl(120 100% 50%)", lime},
+		{"hsl(0.3333turn, 100%, 50%)", lime},
+		{"hwb(0 0% 0%)", red},
+		{"lab(54.29 80.8 69.89)", red},
+		{"lch(54.29 106.84 40.85deg)", red},
+		{"color(srgb 1 0 0)", red},
+		{"oklch(0.628 0.2577 29.23)", Oklch{L: 0.628, C: 0.2577, H: 29.23}},
+		{"oklch(62.8% 0.2577 29.23)", Oklch{L: 0.628, C: 0.2577, H: 29.23}},
+		{"oklch(50% none 0.5turn)", Oklch{L: 0.5, C: 0, H: 180}},
+		{"oklab(0.5 0.1 0)", Oklch{L: 0.5, C: 0.1, H: 0}},
+	}
+
+	for _, c := range cases {
+		got, err := Color(c.input).Evaluate()
+		if err != nil {
+			t.Fatalf("%s: unexpected error %v", c.input, err)
+		}
+
+		if got.DistanceTo(c.want) > 0.002 {
+			t.Fatalf("%s: got %v want %v", c.input, got, c.want)
+		}
+	}
+
+	// p3 red is more saturated than anything srgb can show
+	p3, err := Color("color(display-p3 1 0 0)").Evaluate()
+	if err != nil || p3.C <= red.C {
+		t.Fatalf("display-p3 red: got %v, %v", p3, err)
+	}
+}
+
+func TestEvaluateCssColorErrors(t *testing.T) {
+	for _, input := range []string{
+		"#12345",
+		"#ggg",
+		"transparent",
+		"rgb(1 2)",
+		"rgb(1 2 3 /)",
+		"hsl(red 50% 50%)",
+		"oklch(50% 0.1 12parsecs)",
+		"color(rec2020 1 0 0)",
+		"color()",
+		"fancy(1 2 3)",
+	} {
+		if _, err := Color(input).Evaluate(); err == nil {
+			t.Fatalf("%s: expected an error", input)
+		}
+	}
+}
+
+func TestEvaluateWhiteLiterals(t *testing.T) {
+	warm, err := Color("2700K").Evaluate()
+	if err != nil {
+		t.Fatalf("2700K: %v", err)
+	}
+
+	mireds, err := Color("370 mireds").Evaluate()
+	if err != nil {
+		t.Fatalf("370 mireds: %v", err)
+	}
+
+	if warm.DistanceTo(mireds) > 0.01 {
+		t.Fatalf("2700K and 370 mireds should match, got %v and %v", warm, mireds)
+	}
+
+	// round trips back through the bulb projection
+	if got := (MiredRange{min: 150, max: 500}).Mireds(warm); got < 365 || got > 375 {
+		t.Fatalf("expected 2700K to project to about 370 mireds, got %d", got)
+	}
+
+	x, y := 0.45, 0.41
+	literal, err := Color("xy(0.45, 0.41)").Evaluate()
+	if err != nil {
+		t.Fatalf("xy: %v", err)
+	}
+
+	if gotX, gotY := literal.ToXY(); math.Abs(gotX-x) > 1e-3 || math.Abs(gotY-y) > 1e-3 {
+		t.Fatalf("xy round trip: got (%g, %g)", gotX, gotY)
+	}
+
+	for _, input := range []string{"500K", "90000K", "xy(0.5)", "xy(0.7, 0.6)"} {
+		if _, err := Color(input).Evaluate(); err == nil {
+			t.Fatalf("%s: expected an error", input)
+		}
+	}
+}
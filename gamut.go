@@ -0,0 +1,169 @@
+package main
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+
+	"github.com/BSFishy/lumos/util"
+)
+
+// Gamut is the triangle of xy colors a bulb can actually show.
+type Gamut struct {
+	red, green, blue xyPoint
+}
+
+// d65, the white point every gamut has to contain for chroma reduction to land
+// somewhere
+var whitePoint = xyPoint{X: 0.3127, Y: 0.3290}
+
+var gamutPresets = map[string]Gamut{
+	// sRGB primaries, roughly what cheap rgb bulbs manage
+	"srgb": {red: xyPoint{0.64, 0.33}, green: xyPoint{0.30, 0.60}, blue: xyPoint{0.15, 0.06}},
+
+	// philips hue, A for the early lightstrips and living colors, B for first
+	// generation bulbs and C for everything since
+	"hue_a": {red: xyPoint{0.704, 0.296}, green: xyPoint{0.2151, 0.7106}, blue: xyPoint{0.138, 0.08}},
+	"hue_b": {red: xyPoint{0.675, 0.322}, green: xyPoint{0.409, 0.518}, blue: xyPoint{0.167, 0.04}},
+	"hue_c": {red: xyPoint{0.6915, 0.3083}, green: xyPoint{0.17, 0.7}, blue: xyPoint{0.1532, 0.0475}},
+
+	// ikea tradfri color bulbs, which can't get anywhere near a saturated green
+	"ikea": {red: xyPoint{0.68, 0.31}, green: xyPoint{0.40, 0.55}, blue: xyPoint{0.15, 0.06}},
+}
+
+// Contains reports whether p is inside the triangle, edges included.
+func (g Gamut) Contains(p xyPoint) bool {
+	d1 := cross(g.red, g.green, p)
+	d2 := cross(g.green, g.blue, p)
+	d3 := cross(g.blue, g.red, p)
+
+	hasNeg := d1 < 0 || d2 < 0 || d3 < 0
+	hasPos := d1 > 0 || d2 > 0 || d3 > 0
+	return !(hasNeg && hasPos)
+}
+
+func cross(a, b, p xyPoint) float64 {
+	return (b.X-a.X)*(p.Y-a.Y) - (b.Y-a.Y)*(p.X-a.X)
+}
+
+// Fit reduces chroma, keeping lightness and hue, until the color fits in the
+// gamut. Colors already inside come back untouched.
+func (g Gamut) Fit(color Oklch) Oklch {
+	if g.contains(color) {
+		return color
+	}
+
+	lo, hi := 0.0, color.C
+	for range 24 {
+		mid := (lo + hi) / 2
+		if g.contains(Oklch{L: color.L, C: mid, H: color.H}) {
+			lo = mid
+		} else {
+			hi = mid
+		}
+	}
+
+	return Oklch{L: color.L, C: lo, H: color.H}
+}
+
+func (g Gamut) contains(color Oklch) bool {
+	x, y := color.rawXY()
+	return g.Contains(xyPoint{X: x, Y: y})
+}
+
+type GamutConfig struct {
+	// model ids or zigbee2mqtt model names this applies to
+	Models []string `json:"models"`
+
+	// either a preset name or all three primaries as [x, y]
+	Preset string      `json:"preset"`
+	Red    *[2]float64 `json:"red"`
+	Green  *[2]float64 `json:"green"`
+	Blue   *[2]float64 `json:"blue"`
+}
+
+func (g GamutConfig) Compile() (*Gamut, error) {
+	var errs ValidationErrors
+
+	if len(g.Models) == 0 {
+		errs.Add("models", fmt.Errorf("at least one model is required"))
+	}
+
+	primaries := g.Red != nil || g.Green != nil || g.Blue != nil
+
+	var gamut Gamut
+	switch {
+	case g.Preset != "" && primaries:
+		errs.Add("", fmt.Errorf("expected either a preset or red, green and blue, not both"))
+	case g.Preset != "":
+		preset, ok := gamutPresets[g.Preset]
+		if !ok {
+			errs.Add("preset", fmt.Errorf("unknown preset %q, expected one of %s", g.Preset, strings.Join(gamutPresetNames(), ", ")))
+		}
+
+		gamut = preset
+	case g.Red == nil || g.Green == nil || g.Blue == nil:
+		errs.Add("", fmt.Errorf("expected a preset or all of red, green and blue"))
+	default:
+		gamut.red = collect(&errs, "red", parsePrimary, *g.Red)
+		gamut.green = collect(&errs, "green", parsePrimary, *g.Green)
+		gamut.blue = collect(&errs, "blue", parsePrimary, *g.Blue)
+
+		if errs.Err() == nil && !gamut.Contains(whitePoint) {
+			errs.Add("", fmt.Errorf("gamut must contain the d65 white point (0.3127, 0.3290)"))
+		}
+	}
+
+	return &gamut, errs.Err()
+}
+
+func parsePrimary(p [2]float64) (xyPoint, error) {
+	if p[0] < 0 || p[0] > 1 || p[1] < 0 || p[1] > 1 {
+		return xyPoint{}, fmt.Errorf("x and y must be between 0 and 1, got [%g, %g]", p[0], p[1])
+	}
+
+	return xyPoint{X: p[0], Y: p[1]}, nil
+}
+
+func gamutPresetNames() []string {
+	names := []string{}
+	for name := range gamutPresets {
+		names = append(names, name)
+	}
+
+	slices.Sort(names)
+	return names
+}
+
+// GamutFor finds the gamut for a device, preferring one from the config and
+// falling back to what's known about the model. Nil leaves colors as they are.
+func (c *Config) GamutFor(device Z2MDevice) *Gamut {
+	vendor := ""
+	if device.Definition != nil {
+		vendor = device.Definition.Vendor
+	}
+
+	for _, gamut := range c.Gamuts {
+		if device.IsModel(gamut.Models) {
+			return util.Must(gamut.Compile())
+		}
+	}
+
+	switch {
+	case vendor == "Philips" || vendor == "Signify":
+		preset := gamutPresets["hue_c"]
+		switch {
+		case slices.Contains([]string{"LCT001", "LCT002", "LCT003", "LCT007", "LLM001"}, device.ModelID):
+			preset = gamutPresets["hue_b"]
+		case strings.HasPrefix(device.ModelID, "LLC") || device.ModelID == "LST001":
+			preset = gamutPresets["hue_a"]
+		}
+
+		return &preset
+	case vendor == "IKEA":
+		preset := gamutPresets["ikea"]
+		return &preset
+	default:
+		return nil
+	}
+}
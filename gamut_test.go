@@ -0,0 +1,69 @@
+package main
+
+import (
+	"testing"
+)
+
+func TestGamutFit(t *testing.T) {
+	ikea := gamutPresets["ikea"]
+
+	green := OklchFromSRGB(0, 1, 0)
+	fitted := ikea.Fit(green)
+
+	if fitted.C >= green.C {
+		t.Fatalf("expected chroma to be reduced, got %v from %v", fitted, green)
+	}
+
+	if !almostEqual(fitted.L, green.L) || !almostEqual(fitted.H, green.H) {
+		t.Fatalf("expected lightness and hue to be kept, got %v from %v", fitted, green)
+	}
+
+	x, y := fitted.rawXY()
+	if !ikea.Contains(xyPoint{X: x, Y: y}) {
+		t.Fatalf("fitted color (%g, %g) is outside the gamut", x, y)
+	}
+
+	// hue c is wider than srgb, so srgb colors are left alone
+	if got := gamutPresets["hue_c"].Fit(green); got != green {
+		t.Fatalf("expected %v to fit hue c as is, got %v", green, got)
+	}
+}
+
+func TestGamutConfigValidation(t *testing.T) {
+	cases := []struct {
+		name string
+		cfg  GamutConfig
+		ok   bool
+	}{
+		{"preset", GamutConfig{Models: []string{"LCT015"}, Preset: "hue_c"}, true},
+		{"primaries", GamutConfig{Models: []string{"TS0505B"}, Red: &[2]float64{0.68, 0.31}, Green: &[2]float64{0.17, 0.7}, Blue: &[2]float64{0.15, 0.05}}, true},
+		{"unknown preset", GamutConfig{Models: []string{"x"}, Preset: "hue_d"}, false},
+		{"no models", GamutConfig{Preset: "srgb"}, false},
+		{"missing primary", GamutConfig{Models: []string{"x"}, Red: &[2]float64{0.68, 0.31}}, false},
+		{"no white", GamutConfig{Models: []string{"x"}, Red: &[2]float64{0.7, 0.3}, Green: &[2]float64{0.6, 0.4}, Blue: &[2]float64{0.5, 0.3}}, false},
+	}
+
+	for _, c := range cases {
+		if _, err := c.cfg.Compile(); (err == nil) != c.ok {
+			t.Fatalf("%s: expected ok %v, got %v", c.name, c.ok, err)
+		}
+	}
+}
+
+func TestGamutOnlyFitsColors(t *testing.T) {
+	ikea := gamutPresets["ikea"]
+	green := OklchFromSRGB(0, 1, 0.2)
+	mireds := &MiredRange{min: 153, max: 500}
+
+	// white bulbs project the original color onto the black body curve
+	plain := NewCommand(Target{Color: green}, Capabilities{colorTemp: mireds}, 0)
+	fitted := NewCommand(Target{Color: green}, Capabilities{colorTemp: mireds, gamut: &ikea}, 0)
+	if *plain.ColorTemp != *fitted.ColorTemp {
+		t.Fatalf("expected the gamut not to change the temperature, got %d and %d", *plain.ColorTemp, *fitted.ColorTemp)
+	}
+
+	xy := NewCommand(Target{Color: green}, Capabilities{xy: true, gamut: &ikea}, 0)
+	if !ikea.Contains(*xy.xy) {
+		t.Fatalf("expected xy to be fitted, got %v", *xy.xy)
+	}
+}
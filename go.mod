@@ -2,8 +2,9 @@ module github.com/BSFishy/lumos
 
 go 1.24.4
 
+require github.com/eclipse/paho.mqtt.golang v1.5.0
+
 require (
-	github.com/eclipse/paho.mqtt.golang v1.5.0 // indirect
 	github.com/gorilla/websocket v1.5.3 // indirect
 	golang.org/x/net v0.27.0 // indirect
 	golang.org/x/sync v0.7.0 // indirect
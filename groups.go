@@ -4,12 +4,17 @@ import (
 	"encoding/json"
 	"fmt"
 	"log/slog"
+	"maps"
+	"slices"
+	"time"
 
 	mqtt "github.com/eclipse/paho.mqtt.golang"
 )
 
+// both written from the mqtt handlers and read by config reloads, so guarded by
+// the manager lock
 var (
-	deviceGroups map[string][]string  = nil
+	groupMembers map[string][]string  = nil
 	devices      map[string]Z2MDevice = nil
 )
 
@@ -25,8 +30,10 @@ type Z2MGroupMember struct {
 }
 
 type Z2MDevice struct {
-	FriendlyName string `json:"friendly_name"`
-	IeeeAddress  string `json:"ieee_address"`
+	FriendlyName string         `json:"friendly_name"`
+	IeeeAddress  string         `json:"ieee_address"`
+	ModelID      string         `json:"model_id"`
+	Definition   *Z2MDefinition `json:"definition"`
 }
 
 func setupGroups(client mqtt.Client) {
@@ -56,15 +63,17 @@ func onGroups(c mqtt.Client, m mqtt.Message) {
 		panic(fmt.Errorf("failed to unmarshal groups: %w", err))
 	}
 
-	deviceGroups = map[string][]string{}
+	members := map[string][]string{}
 	for _, group := range payloadGroups {
-		if config.ContainsGroup(group.FriendlyName) {
-			for _, member := range group.Members {
-				deviceGroups[member.IeeeAddress] = append(deviceGroups[member.IeeeAddress], group.FriendlyName)
-			}
+		for _, member := range group.Members {
+			members[group.FriendlyName] = append(members[group.FriendlyName], member.IeeeAddress)
 		}
 	}
 
+	manager.Lock()
+	groupMembers = members
+	manager.Unlock()
+
 	refreshDevices(c)
 }
 
@@ -74,34 +83,175 @@ func onDevices(c mqtt.Client, m mqtt.Message) {
 		panic(fmt.Errorf("failed to unmarshal devices: %w", err))
 	}
 
-	devices = map[string]Z2MDevice{}
+	byAddress := map[string]Z2MDevice{}
 	for _, device := range payloadDevices {
-		devices[device.IeeeAddress] = device
+		byAddress[device.IeeeAddress] = device
 	}
 
+	manager.Lock()
+	devices = byAddress
+	manager.Unlock()
+
 	refreshDevices(c)
 }
 
-func refreshDevices(c mqtt.Client) {
-	if deviceGroups == nil || devices == nil {
-		return
+// groupLights finds the lights in a group and what every one of them supports,
+// since a group command goes to all of them at once. Members that aren't
+// lights ignore it. Must be called with the manager lock held.
+func groupLights(members []string) ([]string, Capabilities, bool) {
+	var caps Capabilities
+	names := []string{}
+	found := false
+
+	for _, ieee := range members {
+		device, ok := devices[ieee]
+		if !ok {
+			continue
+		}
+
+		deviceCaps, ok := deviceCapabilities(device)
+		if !ok {
+			continue
+		}
+
+		names = append(names, device.FriendlyName)
+
+		if !found {
+			caps, found = deviceCaps, true
+			continue
+		}
+
+		caps = caps.intersect(deviceCaps)
+	}
+
+	return names, caps, found && caps.Format() != ""
+}
+
+// deviceCapabilities is what the device supports, along with what the config
+// says about its model. Must be called with the manager lock held.
+func deviceCapabilities(device Z2MDevice) (Capabilities, bool) {
+	caps, ok := device.Capabilities()
+	if !ok {
+		return caps, false
+	}
+
+	caps.gamut = config.GamutFor(device)
+	caps.noTransition = device.IsModel(config.NoTransition)
+	return caps, true
+}
+
+// Must be called with the manager lock held.
+func deviceByName(friendlyName string) (Z2MDevice, bool) {
+	for _, device := range devices {
+		if device.FriendlyName == friendlyName {
+			return device, true
+		}
 	}
 
+	return Z2MDevice{}, false
+}
+
+func refreshDevices(c mqtt.Client) {
 	manager.Lock()
 	defer manager.Unlock()
 
+	if groupMembers == nil || devices == nil {
+		return
+	}
+
+	// the groups each device belongs to that the config cares about
+	deviceGroups := map[string][]string{}
+	for group, members := range groupMembers {
+		if !config.ContainsGroup(group) {
+			continue
+		}
+
+		for _, member := range members {
+			deviceGroups[member] = append(deviceGroups[member], group)
+		}
+	}
+
 	manager.CancelAll()
 
+	// devices driven through a synced group don't get their own manager. A
+	// light can only follow one group, so groups are taken in name order and
+	// any sharing a light with an earlier one is left out.
+	synced := map[string]bool{}
+	for _, group := range slices.Sorted(maps.Keys(groupMembers)) {
+		if !config.SyncsGroup(group) {
+			continue
+		}
+
+		members := groupMembers[group]
+		lights, caps, ok := groupLights(members)
+		if !ok {
+			slog.Info("skipping synced group without controllable lights", "group", group)
+			continue
+		}
+
+		if slices.ContainsFunc(members, func(member string) bool { return synced[member] }) {
+			slog.Warn("skipping synced group that shares lights with another synced group", "group", group)
+			continue
+		}
+
+		manager.StartGroup(c, group, lights, caps, config.Compile([]string{group}))
+		for _, member := range members {
+			synced[member] = true
+		}
+
+		slog.Info("controlling group", "group", group, "format", caps.Format())
+	}
+
+	// lights in a wave follow its timeline instead of picking their own
+	waved := map[string]bool{}
+	for w, group := range config.Groups {
+		if group.Mode != ModeWave {
+			continue
+		}
+
+		wave, offset := config.CompileWave(w)
+		for i, name := range group.Order {
+			device, ok := deviceByName(name)
+			if !ok {
+				slog.Warn("wave light not found", "friendly_name", name)
+				continue
+			}
+
+			if synced[device.IeeeAddress] || waved[device.IeeeAddress] {
+				slog.Warn("light is already driven by another group, leaving it out of the wave", "friendly_name", name)
+				continue
+			}
+
+			caps, ok := deviceCapabilities(device)
+			if !ok {
+				slog.Info("skipping device that isn't a controllable light", "friendly_name", name)
+				continue
+			}
+
+			manager.StartWave(c, name, caps, wave, offset*time.Duration(i))
+			waved[device.IeeeAddress] = true
+
+			slog.Info("controlling device", "friendly_name", name, "format", caps.Format(), "wave_delay", offset*time.Duration(i))
+		}
+	}
+
 	for _, device := range devices {
 		groups, ok := deviceGroups[device.IeeeAddress]
+		if !ok || synced[device.IeeeAddress] || waved[device.IeeeAddress] {
+			continue
+		}
+
+		caps, ok := deviceCapabilities(device)
 		if !ok {
+			slog.Info("skipping device that isn't a controllable light", "friendly_name", device.FriendlyName)
 			continue
 		}
 
-		manager.Start(c, device.FriendlyName, config.Compile(groups))
+		manager.Start(c, device.FriendlyName, caps, config.Compile(groups))
 
-		slog.Info("controlling device", "friendly_name", device.FriendlyName)
+		slog.Info("controlling device", "friendly_name", device.FriendlyName, "format", caps.Format())
 	}
 
+	refreshesTotal.Inc("")
 	slog.Info("config refreshed")
 }
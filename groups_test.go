@@ -0,0 +1,63 @@
+package main
+
+import "testing"
+
+func TestOverlappingSyncedGroups(t *testing.T) {
+	bulb := func(ieee, name string) Z2MDevice {
+		return Z2MDevice{FriendlyName: name, IeeeAddress: ieee, Definition: &Z2MDefinition{
+			Exposes: []Z2MExpose{{Type: "light", Features: []Z2MExpose{{Name: "color_xy"}}}},
+		}}
+	}
+
+	previousManager, previousConfig := manager, config
+	previousMembers, previousDevices := groupMembers, devices
+
+	manager = &Manager{}
+	config = Config{
+		Steps:      1,
+		Transition: Transition{Minimum: "1s", Maximum: "1s"},
+		Hold:       Transition{Minimum: "1s", Maximum: "1s"},
+		Groups: []GroupConfig{
+			{Colors: []PaletteColor{{Color: "#ff0000"}}},
+			{Mode: ModeSync, AppliesTo: []string{"ceiling", "lamps"}},
+		},
+	}
+	groupMembers = map[string][]string{
+		"lamps":   {"0x2", "0x3"},
+		"ceiling": {"0x1", "0x2"},
+	}
+	devices = map[string]Z2MDevice{
+		"0x1": bulb("0x1", "front"),
+		"0x2": bulb("0x2", "middle"),
+		"0x3": bulb("0x3", "back"),
+	}
+
+	t.Cleanup(func() {
+		manager.Lock()
+		manager.CancelAll()
+		manager.Unlock()
+
+		manager, config = previousManager, previousConfig
+		groupMembers, devices = previousMembers, previousDevices
+	})
+
+	refreshDevices(newFakeClient())
+
+	manager.Lock()
+	defer manager.Unlock()
+
+	// ceiling comes first by name, so the light it shares stays with it and
+	// the rest of lamps is driven on its own
+	managers := manager.Managers()
+	if _, ok := managers["ceiling"]; !ok || len(managers) != 2 {
+		t.Fatalf("expected ceiling and one other light, got %v", managers)
+	}
+
+	if _, ok := managers["back"]; !ok {
+		t.Fatalf("expected the light only in lamps to get its own manager, got %v", managers)
+	}
+
+	if manager.Light("middle").group != manager.Light("ceiling") {
+		t.Fatal("expected the shared light to follow ceiling")
+	}
+}
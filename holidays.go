@@ -0,0 +1,209 @@
+package main
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// DayOfYear is a date that can move from year to year, like Thanksgiving or
+// Easter, plus an offset in days.
+type DayOfYear struct {
+	// resolve returns the date in the given year, or false if it doesn't happen
+	// that year
+	resolve func(year int) (time.Time, bool)
+	offset  int
+}
+
+// YearDay returns the 0-based day of year the date lands on in year. Offsets
+// pushing it into a neighbouring year wrap around, the same way fades across
+// new year already do.
+func (d DayOfYear) YearDay(year int) (int, bool) {
+	date, ok := d.resolve(year)
+	if !ok {
+		return 0, false
+	}
+
+	jan1 := time.Date(year, time.January, 1, 0, 0, 0, 0, time.UTC)
+	date = time.Date(date.Year(), date.Month(), date.Day()+d.offset, 0, 0, 0, 0, time.UTC)
+
+	days := int(date.Sub(jan1).Hours() / 24)
+	return distFwdDays(0, days, yearLength(year)), true
+}
+
+var dateOffsetPattern = regexp.MustCompile(`^(.*?)\s*([+-]\d+)([dw])$`)
+
+var ordinals = map[string]int{
+	"1st": 1, "first": 1,
+	"2nd": 2, "second": 2,
+	"3rd": 3, "third": 3,
+	"4th": 4, "fourth": 4,
+	"5th": 5, "fifth": 5,
+	"last": -1,
+}
+
+// parseDayOfYear accepts:
+//
+//	12-25                        a fixed date
+//	4th thursday of november     the nth weekday of a month
+//	last monday of may           the last weekday of a month
+//	easter                       western easter sunday
+//	2025-01-29, 2026-02-17       explicit dates, only active in the listed years
+//
+// any of which can be followed by an offset like +1d, -2d or +1w.
+func parseDayOfYear(s string) (DayOfYear, error) {
+	rule := strings.ToLower(strings.TrimSpace(s))
+
+	offset := 0
+	if m := dateOffsetPattern.FindStringSubmatch(rule); m != nil {
+		n, err := strconv.Atoi(m[2])
+		if err != nil {
+			return DayOfYear{}, fmt.Errorf("invalid offset in %q", s)
+		}
+
+		if m[3] == "w" {
+			n *= 7
+		}
+
+		rule, offset = m[1], n
+	}
+
+	resolve, err := parseDateRule(rule)
+	if err != nil {
+		return DayOfYear{}, fmt.Errorf("invalid date %q: %w", s, err)
+	}
+
+	return DayOfYear{resolve: resolve, offset: offset}, nil
+}
+
+func parseDateRule(rule string) (func(int) (time.Time, bool), error) {
+	if rule == "easter" {
+		return func(year int) (time.Time, bool) {
+			return easter(year), true
+		}, nil
+	}
+
+	if t, err := time.Parse(dayLayout, rule); err == nil {
+		return func(year int) (time.Time, bool) {
+			return time.Date(year, t.Month(), t.Day(), 0, 0, 0, 0, time.UTC), true
+		}, nil
+	}
+
+	if strings.Contains(rule, " of ") {
+		return parseWeekdayRule(rule)
+	}
+
+	if strings.Contains(rule, ",") || len(rule) == len(time.DateOnly) {
+		return parseDateList(rule)
+	}
+
+	return nil, fmt.Errorf("expected something like 12-25, 4th thursday of november, easter-2d or 2025-01-29")
+}
+
+func parseWeekdayRule(rule string) (func(int) (time.Time, bool), error) {
+	fields := strings.Fields(rule)
+	if len(fields) != 4 || fields[2] != "of" {
+		return nil, fmt.Errorf("expected something like 4th thursday of november")
+	}
+
+	nth, ok := ordinals[fields[0]]
+	if !ok {
+		return nil, fmt.Errorf("unknown ordinal %q, expected 1st to 5th or last", fields[0])
+	}
+
+	weekday, ok := parseWeekday(fields[1])
+	if !ok {
+		return nil, fmt.Errorf("unknown weekday %q", fields[1])
+	}
+
+	month, ok := parseMonth(fields[3])
+	if !ok {
+		return nil, fmt.Errorf("unknown month %q", fields[3])
+	}
+
+	return func(year int) (time.Time, bool) {
+		return nthWeekday(year, month, weekday, nth)
+	}, nil
+}
+
+func parseDateList(rule string) (func(int) (time.Time, bool), error) {
+	dates := map[int]time.Time{}
+	for _, part := range strings.Split(rule, ",") {
+		t, err := time.Parse(time.DateOnly, strings.TrimSpace(part))
+		if err != nil {
+			return nil, fmt.Errorf("invalid date %q in list, expected 2025-01-29", strings.TrimSpace(part))
+		}
+
+		if _, ok := dates[t.Year()]; ok {
+			return nil, fmt.Errorf("more than one date listed for %d", t.Year())
+		}
+
+		dates[t.Year()] = t
+	}
+
+	return func(year int) (time.Time, bool) {
+		t, ok := dates[year]
+		return t, ok
+	}, nil
+}
+
+func parseWeekday(s string) (time.Weekday, bool) {
+	for d := time.Sunday; d <= time.Saturday; d++ {
+		name := strings.ToLower(d.String())
+		if s == name || s == name[:3] {
+			return d, true
+		}
+	}
+
+	return 0, false
+}
+
+func parseMonth(s string) (time.Month, bool) {
+	for m := time.January; m <= time.December; m++ {
+		name := strings.ToLower(m.String())
+		if s == name || s == name[:3] {
+			return m, true
+		}
+	}
+
+	return 0, false
+}
+
+// nthWeekday finds the nth (1 based, or -1 for last) weekday of a month.
+// Returns false if the month doesn't have that many, like a 5th monday.
+func nthWeekday(year int, month time.Month, weekday time.Weekday, nth int) (time.Time, bool) {
+	if nth < 0 {
+		last := time.Date(year, month+1, 0, 0, 0, 0, 0, time.UTC)
+		back := (int(last.Weekday()) - int(weekday) + 7) % 7
+		return last.AddDate(0, 0, -back), true
+	}
+
+	first := time.Date(year, month, 1, 0, 0, 0, 0, time.UTC)
+	forward := (int(weekday) - int(first.Weekday()) + 7) % 7
+	date := first.AddDate(0, 0, forward+7*(nth-1))
+
+	return date, date.Month() == month
+}
+
+// easter sunday in the gregorian calendar, using the anonymous gregorian
+// algorithm
+func easter(year int) time.Time {
+	a := year % 19
+	b := year / 100
+	c := year % 100
+	d := b / 4
+	e := b % 4
+	f := (b + 8) / 25
+	g := (b - f + 1) / 3
+	h := (19*a + b - d - g + 15) % 30
+	i := c / 4
+	k := c % 4
+	l := (32 + 2*e + 2*i - h - k) % 7
+	m := (a + 11*h + 22*l) / 451
+	month := (h + l - 7*m + 114) / 31
+	day := (h+l-7*m+114)%31 + 1
+
+	return time.Date(year, time.Month(month), day, 0, 0, 0, 0, time.UTC)
+}
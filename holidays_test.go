@@ -0,0 +1,87 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+func TestParseDayOfYear(t *testing.T) {
+	cases := []struct {
+		rule  string
+		year  int
+		month time.Month
+		day   int
+	}{
+		{"12-25", 2025, time.December, 25},
+		{"4th thursday of november", 2025, time.November, 27},
+		{"4th Thu of Nov+1d", 2025, time.November, 28},
+		{"last monday of may", 2025, time.May, 26},
+		{"1st monday of september", 2024, time.September, 2},
+		{"easter", 2025, time.April, 20},
+		{"easter", 2024, time.March, 31},
+		{"easter-2d", 2025, time.April, 18},
+		{"easter+1w", 2025, time.April, 27},
+		{"2025-01-29, 2026-02-17", 2026, time.February, 17},
+	}
+
+	for _, c := range cases {
+		d, err := parseDayOfYear(c.rule)
+		if err != nil {
+			t.Fatalf("%s: %v", c.rule, err)
+		}
+
+		got, ok := d.YearDay(c.year)
+		want := time.Date(c.year, c.month, c.day, 0, 0, 0, 0, time.UTC).YearDay() - 1
+		if !ok || got != want {
+			t.Fatalf("%s in %d: got day %d (%v) want %d", c.rule, c.year, got, ok, want)
+		}
+	}
+}
+
+func TestDayOfYearMissing(t *testing.T) {
+	list, err := parseDayOfYear("2025-01-29, 2026-02-17")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, ok := list.YearDay(2027); ok {
+		t.Fatal("expected no date for an unlisted year")
+	}
+
+	// june 2025 only has four thursdays
+	fifth, err := parseDayOfYear("5th thursday of june")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, ok := fifth.YearDay(2025); ok {
+		t.Fatal("expected no 5th thursday in june 2025")
+	}
+
+	for _, rule := range []string{"thanksgiving", "6th monday of may", "last funday of may", "2025-13-01"} {
+		if _, err := parseDayOfYear(rule); err == nil {
+			t.Fatalf("expected %q to be invalid", rule)
+		}
+	}
+}
+
+func TestDateOverlayThanksgiving(t *testing.T) {
+	l := useLocation(t, "America/Chicago")
+
+	overlay, err := SeasonalConfig{
+		FadeIn:  DateFader{Start: "4th thursday of november-2d", End: "4th thursday of november"},
+		FadeOut: DateFader{Start: "4th thursday of november+1d", End: "4th thursday of november+3d"},
+	}.Compile()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// thanksgiving lands on the 27th in 2025 and the 28th in 2024
+	if got := overlay.Mix(time.Date(2025, time.November, 27, 12, 0, 0, 0, l)); got != 1 {
+		t.Fatalf("thanksgiving 2025: got %f", got)
+	}
+
+	if got := overlay.Mix(time.Date(2024, time.November, 27, 12, 0, 0, 0, l)); !almostEqual(got, 0.5) {
+		t.Fatalf("day before thanksgiving 2024: got %f", got)
+	}
+}
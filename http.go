@@ -0,0 +1,192 @@
+package main
+
+import (
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"os"
+	"slices"
+	"strings"
+	"time"
+)
+
+func SetupHttp() {
+	addr, ok := os.LookupEnv("HTTP_ADDR")
+	if !ok {
+		addr = ":8080"
+	}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /api/lights", handleLights)
+	mux.HandleFunc("GET /api/lights/{name...}", handleLight)
+	mux.HandleFunc("GET /metrics", handleMetrics)
+
+	go func() {
+		slog.Info("http server listening", "addr", addr)
+
+		if err := http.ListenAndServe(addr, mux); err != nil {
+			slog.Error("http server stopped", "err", err)
+		}
+	}()
+}
+
+type ColorStatus struct {
+	L   float64 `json:"l"`
+	C   float64 `json:"c"`
+	H   float64 `json:"h"`
+	X   float64 `json:"x"`
+	Y   float64 `json:"y"`
+	Hex string  `json:"hex"`
+
+	// zigbee2mqtt brightness, only set for groups that manage it
+	Brightness *int `json:"brightness,omitempty"`
+}
+
+func NewColorStatus(target Target) ColorStatus {
+	color := target.Color
+	x, y := color.ToXY()
+
+	status := ColorStatus{
+		L:   color.L,
+		C:   color.C,
+		H:   color.H,
+		X:   x,
+		Y:   y,
+		Hex: color.Hex(),
+	}
+
+	if target.HasBrightness {
+		brightness := toBrightness(target.Brightness)
+		status.Brightness = &brightness
+	}
+
+	return status
+}
+
+type OverlayStatus struct {
+	Name string  `json:"name"`
+	Mix  float64 `json:"mix"`
+}
+
+type LightStatus struct {
+	FriendlyName string        `json:"friendly_name"`
+	Format       PayloadFormat `json:"format"`
+
+	// the lights a synced group drives
+	Members []string `json:"members,omitempty"`
+
+	Active      bool       `json:"active"`
+	Paused      bool       `json:"paused"`
+	PausedUntil *time.Time `json:"paused_until,omitempty"`
+	Power       string     `json:"power,omitempty"`
+
+	Previous        ColorStatus `json:"previous"`
+	Next            ColorStatus `json:"next"`
+	TransitionStart time.Time   `json:"transition_start"`
+	TransitionEnd   time.Time   `json:"transition_end"`
+
+	// the overlay the next color was picked from, nil when ambient
+	Overlay  *OverlayStatus  `json:"overlay"`
+	Overlays []OverlayStatus `json:"overlays"`
+}
+
+// Status captures what the light is doing right now.
+func (c *ColorManager) Status() LightStatus {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	status := LightStatus{
+		FriendlyName: c.friendlyName,
+		Format:       c.caps.Format(),
+		Members:      c.memberNames,
+
+		Previous:        NewColorStatus(c.previous),
+		Next:            NewColorStatus(c.next),
+		TransitionStart: c.start,
+		TransitionEnd:   c.end,
+
+		Overlays: make([]OverlayStatus, len(c.cfg.overlays)),
+	}
+
+	now := c.cfg.clock.Now()
+
+	for i := range c.cfg.overlays {
+		overlay := &c.cfg.overlays[i]
+		status.Overlays[i] = OverlayStatus{
+			Name: overlay.name,
+			Mix:  overlay.Mix(now),
+		}
+
+		if overlay == c.overlay {
+			status.Overlay = &status.Overlays[i]
+		}
+	}
+
+	c.state.fillStatus(&status)
+
+	// synced groups also wait on their lights
+	status.Active = c.active()
+	return status
+}
+
+func (s *LightState) fillStatus(status *LightStatus) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	status.Active = s.active()
+	status.Paused = s.paused
+	status.Power = s.power
+
+	if !s.until.IsZero() {
+		until := s.until
+		status.PausedUntil = &until
+	}
+}
+
+func lightStatuses() []LightStatus {
+	manager.Lock()
+	managers := make([]*ColorManager, 0, len(manager.Managers()))
+	for _, cm := range manager.Managers() {
+		managers = append(managers, cm)
+	}
+	manager.Unlock()
+
+	statuses := make([]LightStatus, len(managers))
+	for i, cm := range managers {
+		statuses[i] = cm.Status()
+	}
+
+	slices.SortFunc(statuses, func(a, b LightStatus) int {
+		return strings.Compare(a.FriendlyName, b.FriendlyName)
+	})
+
+	return statuses
+}
+
+func handleLights(w http.ResponseWriter, r *http.Request) {
+	writeJson(w, http.StatusOK, lightStatuses())
+}
+
+func handleLight(w http.ResponseWriter, r *http.Request) {
+	name := r.PathValue("name")
+
+	manager.Lock()
+	cm, ok := manager.Managers()[name]
+	manager.Unlock()
+
+	if !ok {
+		writeJson(w, http.StatusNotFound, map[string]string{"error": "light not found"})
+		return
+	}
+
+	writeJson(w, http.StatusOK, cm.Status())
+}
+
+func writeJson(w http.ResponseWriter, status int, body any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+
+	if err := json.NewEncoder(w).Encode(body); err != nil {
+		slog.Warn("failed to write http response", "err", err)
+	}
+}
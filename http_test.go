@@ -0,0 +1,94 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestLightStatusApi(t *testing.T) {
+	start := time.Date(2025, time.June, 10, 12, 0, 0, 0, time.UTC)
+	clock := NewFakeClock(start.Add(15 * time.Second))
+
+	cfg := RuntimeConfig{
+		clock:    clock,
+		overlays: []Overlay{{name: "evening"}},
+	}
+
+	state := NewLightState()
+	state.Pause(0)
+
+	cm := &ColorManager{
+		friendlyName: "desk",
+		cfg:          cfg,
+		state:        state,
+		previous:     Target{Color: Oklch{L: 0.5}},
+		next:         Target{Color: Oklch{L: 0.7, C: 0.1, H: 30}, Brightness: 0.5, HasBrightness: true},
+		start:        start,
+		end:          start.Add(30 * time.Second),
+	}
+	cm.overlay = &cm.cfg.overlays[0]
+
+	manager.Lock()
+	previous := manager.managers
+	manager.managers = map[string]*ColorManager{"desk": cm}
+	manager.Unlock()
+	t.Cleanup(func() {
+		manager.Lock()
+		manager.managers = previous
+		manager.Unlock()
+	})
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /api/lights", handleLights)
+	mux.HandleFunc("GET /api/lights/{name...}", handleLight)
+
+	get := func(path string, body any) int {
+		recorder := httptest.NewRecorder()
+		mux.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, path, nil))
+
+		if body != nil {
+			if err := json.Unmarshal(recorder.Body.Bytes(), body); err != nil {
+				t.Fatalf("%s: invalid json: %v", path, err)
+			}
+		}
+
+		return recorder.Code
+	}
+
+	var lights []LightStatus
+	if code := get("/api/lights", &lights); code != http.StatusOK || len(lights) != 1 {
+		t.Fatalf("list: got %d with %d lights", code, len(lights))
+	}
+
+	var light LightStatus
+	if code := get("/api/lights/desk", &light); code != http.StatusOK {
+		t.Fatalf("get: got %d", code)
+	}
+
+	if light.FriendlyName != "desk" || light.Active || !light.Paused {
+		t.Fatalf("unexpected status %+v", light)
+	}
+
+	if !light.TransitionStart.Equal(start) || !light.TransitionEnd.Equal(start.Add(30*time.Second)) {
+		t.Fatalf("unexpected transition %s to %s", light.TransitionStart, light.TransitionEnd)
+	}
+
+	if !almostEqual(light.Next.L, 0.7) || light.Next.Brightness == nil || *light.Next.Brightness != toBrightness(0.5) {
+		t.Fatalf("unexpected next color %+v", light.Next)
+	}
+
+	if light.Previous.Brightness != nil {
+		t.Fatalf("expected no brightness for a color only target, got %d", *light.Previous.Brightness)
+	}
+
+	if light.Overlay == nil || light.Overlay.Name != "evening" || light.Overlay.Mix != 1 || len(light.Overlays) != 1 {
+		t.Fatalf("expected the evening overlay to be picked, got %+v", light.Overlay)
+	}
+
+	if code := get("/api/lights/missing", nil); code != http.StatusNotFound {
+		t.Fatalf("missing light: got %d", code)
+	}
+}
@@ -0,0 +1,191 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"strings"
+)
+
+type InterpolationSpace string
+
+const (
+	// oklch taking the shorter way around the hue wheel, the default
+	InterpolateOklch InterpolationSpace = "oklch"
+	// oklch taking the longer way around, sweeping through more hues
+	InterpolateOklchLong InterpolationSpace = "oklch_long"
+	// a straight line in oklab, which passes closer to gray between
+	// opposite hues
+	InterpolateOklab InterpolationSpace = "oklab"
+	// mixing light, the way two bulbs next to each other blend
+	InterpolateLinearSRGB InterpolationSpace = "linear_srgb"
+	// a straight line in CIE xy, the way a bulb fading on its own would go
+	InterpolateXY InterpolationSpace = "xy"
+)
+
+// Interpolation is how a light moves between colors: the space it blends in
+// and the easing applied to progress through the transition.
+type Interpolation struct {
+	space InterpolationSpace
+	ease  func(float64) float64
+}
+
+func (i *Interpolation) Lerp(from, to Oklch, t float64) Oklch {
+	switch i.space {
+	case InterpolateOklchLong:
+		return Oklch{
+			L: from.L + (to.L-from.L)*t,
+			C: from.C + (to.C-from.C)*t,
+			H: lerpHueLong(from.H, to.H, t),
+		}
+	case InterpolateOklab:
+		fa, fb := from.ab()
+		ta, tb := to.ab()
+		return oklchFromOklab(from.L+(to.L-from.L)*t, fa+(ta-fa)*t, fb+(tb-fb)*t)
+	case InterpolateLinearSRGB:
+		fr, fg, fb := from.toLinearSRGB()
+		tr, tg, tb := to.toLinearSRGB()
+		return OklchFromLinearSRGB(fr+(tr-fr)*t, fg+(tg-fg)*t, fb+(tb-fb)*t)
+	case InterpolateXY:
+		return lerpXY(from, to, t)
+	default:
+		return from.Lerp(to, t)
+	}
+}
+
+func lerpHueLong(a, b, t float64) float64 {
+	d := math.Mod(math.Mod(b-a, 360)+360, 360)
+	if d != 0 && d < 180 {
+		d -= 360
+	}
+
+	return math.Mod(math.Mod(a+d*t, 360)+360, 360)
+}
+
+func (c Oklch) ab() (a, b float64) {
+	h := c.H * math.Pi / 180
+	return c.C * math.Cos(h), c.C * math.Sin(h)
+}
+
+func oklchFromOklab(l, a, b float64) Oklch {
+	h := math.Atan2(b, a) * 180 / math.Pi
+	if h < 0 {
+		h += 360
+	}
+
+	return Oklch{L: l, C: math.Hypot(a, b), H: h}
+}
+
+// lerpXY moves in a straight line across the chromaticity diagram while
+// blending luminance separately.
+func lerpXY(from, to Oklch, t float64) Oklch {
+	fx, fy, fY := from.xyY()
+	tx, ty, tY := to.xyY()
+
+	x, y, Y := fx+(tx-fx)*t, fy+(ty-fy)*t, fY+(tY-fY)*t
+	if y <= 0 {
+		return from.Lerp(to, t)
+	}
+
+	return OklchFromLinearSRGB(xyzD65ToLinearSRGB(x*Y/y, Y, (1-x-y)*Y/y))
+}
+
+func (c Oklch) xyY() (x, y, Y float64) {
+	rl, gl, bl := c.toLinearSRGB()
+	x, y = linearToXY(rl, gl, bl)
+	Y = 0.2126729*rl + 0.7151522*gl + 0.0721750*bl
+
+	// black has no chromaticity, borrow white's so fading to it doesn't
+	// swing through other hues
+	if x == 0 && y == 0 {
+		x, y = whitePoint.X, whitePoint.Y
+	}
+
+	return x, y, Y
+}
+
+var easings = map[string]func(float64) float64{
+	"linear":      func(t float64) float64 { return t },
+	"ease":        cubicBezier(0.25, 0.1, 0.25, 1),
+	"ease-in":     cubicBezier(0.42, 0, 1, 1),
+	"ease-out":    cubicBezier(0, 0, 0.58, 1),
+	"ease-in-out": cubicBezier(0.42, 0, 0.58, 1),
+	"sine":        func(t float64) float64 { return 0.5 - 0.5*math.Cos(math.Pi*t) },
+}
+
+// parseEasing accepts the keywords and cubic-bezier(x1, y1, x2, y2) from css
+// transition-timing-function, plus sine. ease_in and friends are accepted too,
+// to match how the rest of the config is spelled.
+func parseEasing(s string) (func(float64) float64, error) {
+	s = strings.ToLower(strings.TrimSpace(s))
+	if s == "" {
+		return easings["linear"], nil
+	}
+
+	if ease, ok := easings[strings.ReplaceAll(s, "_", "-")]; ok {
+		return ease, nil
+	}
+
+	name, params, ok := colorFunction(s)
+	if !ok || name != "cubic-bezier" {
+		return nil, fmt.Errorf("unknown easing %q, expected linear, ease, ease-in, ease-out, ease-in-out, sine or cubic-bezier(x1, y1, x2, y2)", s)
+	}
+
+	if len(params) != 4 {
+		return nil, fmt.Errorf("cubic-bezier() takes 4 parameters, got %d", len(params))
+	}
+
+	p, err := components(params, []string{"x1", "y1", "x2", "y2"}, []func(string) (float64, error){number(1), number(1), number(1), number(1)})
+	if err != nil {
+		return nil, fmt.Errorf("invalid cubic-bezier(): %w", err)
+	}
+
+	if p[0] < 0 || p[0] > 1 || p[2] < 0 || p[2] > 1 {
+		return nil, errors.New("cubic-bezier() x values must be between 0 and 1")
+	}
+
+	return cubicBezier(p[0], p[1], p[2], p[3]), nil
+}
+
+// cubicBezier is the css timing function with control points (x1, y1) and
+// (x2, y2). x is monotonic when x1 and x2 are in [0, 1], so it can be inverted
+// by bisection.
+func cubicBezier(x1, y1, x2, y2 float64) func(float64) float64 {
+	bezier := func(a, b, s float64) float64 {
+		return 3*a*s*(1-s)*(1-s) + 3*b*s*s*(1-s) + s*s*s
+	}
+
+	return func(t float64) float64 {
+		if t <= 0 || t >= 1 {
+			return clamp01(t)
+		}
+
+		lo, hi := 0.0, 1.0
+		for range 32 {
+			mid := (lo + hi) / 2
+			if bezier(x1, x2, mid) < t {
+				lo = mid
+			} else {
+				hi = mid
+			}
+		}
+
+		return bezier(y1, y2, (lo+hi)/2)
+	}
+}
+
+var interpolationSpaces = []InterpolationSpace{InterpolateOklch, InterpolateOklchLong, InterpolateOklab, InterpolateLinearSRGB, InterpolateXY}
+
+func parseInterpolationSpace(s string) (InterpolationSpace, error) {
+	if s == "" {
+		return InterpolateOklch, nil
+	}
+
+	for _, space := range interpolationSpaces {
+		if string(space) == s {
+			return space, nil
+		}
+	}
+
+	return "", fmt.Errorf("unknown interpolation %q, expected oklch, oklch_long, oklab, linear_srgb or xy", s)
+}
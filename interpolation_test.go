@@ -0,0 +1,111 @@
+package main
+
+import (
+	"math"
+	"testing"
+)
+
+func TestEasings(t *testing.T) {
+	for name, ease := range easings {
+		if !almostEqual(ease(0), 0) || !almostEqual(ease(1), 1) {
+			t.Fatalf("%s: expected to start at 0 and end at 1, got %g and %g", name, ease(0), ease(1))
+		}
+
+		previous := 0.0
+		for i := 1; i <= 100; i++ {
+			v := ease(float64(i) / 100)
+			if v < previous-1e-9 {
+				t.Fatalf("%s: not monotonic at %d", name, i)
+			}
+
+			previous = v
+		}
+	}
+
+	if v := easings["ease-in"](0.5); v >= 0.5 {
+		t.Fatalf("ease-in should lag behind halfway, got %g", v)
+	}
+
+	// css keywords, and the snake case spelling the rest of the config uses
+	for _, input := range []string{"ease-in-out", "ease_in_out", "EASE-IN-OUT"} {
+		ease, err := parseEasing(input)
+		if err != nil {
+			t.Fatalf("%s: %v", input, err)
+		}
+
+		if v := ease(0.25); !almostEqual(v, easings["ease-in-out"](0.25)) {
+			t.Fatalf("%s: expected ease-in-out, got %g at 0.25", input, v)
+		}
+	}
+
+	identity, err := parseEasing("cubic-bezier(0, 0, 1, 1)")
+	if err != nil {
+		t.Fatalf("cubic-bezier: %v", err)
+	}
+
+	if v := identity(0.3); math.Abs(v-0.3) > 1e-6 {
+		t.Fatalf("cubic-bezier(0, 0, 1, 1) should be linear, got %g", v)
+	}
+
+	for _, input := range []string{"bouncy", "cubic-bezier(0, 0, 1)", "cubic-bezier(1.5, 0, 1, 1)"} {
+		if _, err := parseEasing(input); err == nil {
+			t.Fatalf("%s: expected an error", input)
+		}
+	}
+}
+
+func TestInterpolationSpaces(t *testing.T) {
+	from := Oklch{L: 0.6, C: 0.15, H: 10}
+	to := Oklch{L: 0.6, C: 0.15, H: 350}
+
+	short := (&Interpolation{space: InterpolateOklch}).Lerp(from, to, 0.5)
+	long := (&Interpolation{space: InterpolateOklchLong}).Lerp(from, to, 0.5)
+	if !almostEqual(short.H, 0) && !almostEqual(short.H, 360) {
+		t.Fatalf("short hue: expected 0, got %g", short.H)
+	}
+
+	if !almostEqual(long.H, 180) {
+		t.Fatalf("long hue: expected 180, got %g", long.H)
+	}
+
+	// opposite hues meet at gray in a straight oklab line
+	opposite := Oklch{L: 0.6, C: 0.15, H: 190}
+	if mid := (&Interpolation{space: InterpolateOklab}).Lerp(from, opposite, 0.5); mid.C > 1e-6 {
+		t.Fatalf("oklab: expected gray halfway between opposite hues, got %v", mid)
+	}
+
+	red, green := OklchFromSRGB(1, 0, 0), OklchFromSRGB(0, 1, 0)
+	mid := (&Interpolation{space: InterpolateLinearSRGB}).Lerp(red, green, 0.5)
+	if r, g, b := mid.toLinearSRGB(); math.Abs(r-0.5) > 1e-6 || math.Abs(g-0.5) > 1e-6 || math.Abs(b) > 1e-6 {
+		t.Fatalf("linear srgb: expected an even mix, got %g %g %g", r, g, b)
+	}
+
+	xy := &Interpolation{space: InterpolateXY}
+	for _, f := range []float64{0, 1} {
+		want := red
+		if f == 1 {
+			want = green
+		}
+
+		if got := xy.Lerp(red, green, f); got.DistanceTo(want) > 1e-4 {
+			t.Fatalf("xy at %g: got %v want %v", f, got, want)
+		}
+	}
+}
+
+func TestTargetLerpEases(t *testing.T) {
+	from := Target{Color: Oklch{L: 0.2}, Brightness: 0, HasBrightness: true}
+	to := Target{
+		Color:         Oklch{L: 0.8},
+		Brightness:    1,
+		HasBrightness: true,
+		interpolation: &Interpolation{space: InterpolateOklch, ease: easings["ease-in"]},
+	}
+
+	// color and brightness follow the same curve
+	mid := from.Lerp(to, 0.5)
+	eased := easings["ease-in"](0.5)
+	if !almostEqual(mid.Color.L, 0.2+0.6*eased) || !almostEqual(mid.Brightness, eased) || eased >= 0.5 {
+		t.Fatalf("expected eased progress to lag behind halfway, got %+v", mid)
+	}
+}
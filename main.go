@@ -9,8 +9,13 @@ import (
 )
 
 func main() {
+	if len(os.Args) > 1 {
+		os.Exit(RunCommand(os.Args[1], os.Args[2:]))
+	}
+
 	SetupLogger()
 	SetupConfig()
+	SetupHttp()
 	SetupMqtt()
 
 	ctx, stop := signal.NotifyContext(context.Background(),
@@ -19,6 +24,8 @@ func main() {
 	)
 	defer stop()
 
+	go WatchConfig(ctx)
+
 	slog.Info("waiting for exit signal")
 
 	<-ctx.Done()
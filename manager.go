@@ -16,7 +16,9 @@ var manager = &Manager{}
 type Manager struct {
 	mu sync.Mutex
 
-	cancels []context.CancelFunc
+	cancels  []context.CancelFunc
+	lights   map[string]*LightState
+	managers map[string]*ColorManager
 }
 
 func (m *Manager) Lock() {
@@ -31,18 +33,93 @@ func (m *Manager) addCancel(cancel context.CancelFunc) {
 	m.cancels = append(m.cancels, cancel)
 }
 
+// Light returns the state for a light, creating it if needed. Must be called
+// with the lock held.
+func (m *Manager) Light(friendlyName string) *LightState {
+	if m.lights == nil {
+		m.lights = map[string]*LightState{}
+	}
+
+	state, ok := m.lights[friendlyName]
+	if !ok {
+		state = NewLightState()
+		m.lights[friendlyName] = state
+	}
+
+	return state
+}
+
+// LightNames returns every light the manager has seen. Must be called with the
+// lock held.
+func (m *Manager) LightNames() []string {
+	names := make([]string, 0, len(m.lights))
+	for name := range m.lights {
+		names = append(names, name)
+	}
+
+	return names
+}
+
 func (m *Manager) CancelAll() {
 	for _, cancel := range m.cancels {
 		cancel()
 	}
 
 	m.cancels = []context.CancelFunc{}
+	m.managers = map[string]*ColorManager{}
 }
 
-func (m *Manager) Start(c mqtt.Client, friendlyName string, cfg RuntimeConfig) {
+// Managers returns the running ColorManager for every controlled light. Must
+// be called with the lock held.
+func (m *Manager) Managers() map[string]*ColorManager {
+	return m.managers
+}
+
+func (m *Manager) Start(c mqtt.Client, friendlyName string, caps Capabilities, cfg RuntimeConfig) {
+	m.Light(friendlyName).SetGroup(nil)
+	m.start(c, friendlyName, nil, caps, cfg, nil)
+}
+
+// StartGroup drives a whole zigbee2mqtt group through its group topic.
+func (m *Manager) StartGroup(c mqtt.Client, group string, members []string, caps Capabilities, cfg RuntimeConfig) {
+	m.start(c, group, members, caps, cfg, nil)
+}
+
+// StartWave drives a light as part of a wave, delay behind the start of it.
+func (m *Manager) StartWave(c mqtt.Client, friendlyName string, caps Capabilities, wave *Wave, delay time.Duration) {
+	m.Light(friendlyName).SetGroup(nil)
+	m.start(c, friendlyName, nil, caps, wave.cfg, waveSchedule{wave: wave, delay: delay})
+}
+
+func (m *Manager) start(c mqtt.Client, friendlyName string, members []string, caps Capabilities, cfg RuntimeConfig, schedule Schedule) {
 	ctx, cancel := context.WithCancel(context.Background())
 	m.addCancel(cancel)
 
+	cm := &ColorManager{
+		client:       c,
+		friendlyName: friendlyName,
+		caps:         caps,
+		cfg:          cfg,
+		schedule:     schedule,
+		state:        m.Light(friendlyName),
+		memberNames:  members,
+	}
+
+	if cm.schedule == nil {
+		cm.schedule = independentSchedule{cfg: &cm.cfg}
+	}
+
+	for _, member := range members {
+		state := m.Light(member)
+		state.SetGroup(cm.state)
+		cm.members = append(cm.members, state)
+	}
+
+	if m.managers == nil {
+		m.managers = map[string]*ColorManager{}
+	}
+	m.managers[friendlyName] = cm
+
 	go func() {
 		defer func() {
 			if err := recover(); err != nil {
@@ -50,12 +127,6 @@ func (m *Manager) Start(c mqtt.Client, friendlyName string, cfg RuntimeConfig) {
 			}
 		}()
 
-		cm := &ColorManager{
-			client:       c,
-			friendlyName: friendlyName,
-			cfg:          cfg,
-		}
-
 		cm.Run(ctx)
 	}()
 }
@@ -64,48 +135,108 @@ type ColorManager struct {
 	client mqtt.Client
 
 	friendlyName string
+	caps         Capabilities
 	cfg          RuntimeConfig
+	schedule     Schedule
+	state        *LightState
 
-	previousColor, nextColor Oklch
-	start, end               time.Time
+	// lights in the group when friendlyName is a synced zigbee2mqtt group
+	memberNames []string
+	members     []*LightState
+
+	// guards the fields below, which are only written from Run but read by the
+	// http api
+	mu sync.Mutex
+
+	previous, next Target
+	start, end     time.Time
+	overlay        *Overlay
 }
 
 func (c *ColorManager) Run(ctx context.Context) {
 	topic := fmt.Sprintf("zigbee2mqtt/%s/set", c.friendlyName)
-	c.previousColor = c.cfg.SelectColor()
+	c.mu.Lock()
+	c.previous = c.schedule.Initial(c.cfg.clock.Now())
+	c.mu.Unlock()
 
 outer:
 	for {
-		c.nextColor = c.cfg.SelectColor()
+		if !c.waitActive(ctx) {
+			return
+		}
 
-		duration := c.cfg.Transition()
+		cycle := c.schedule.Next(c.cfg.clock.Now(), c.previous)
 
-		c.start = time.Now()
-		c.end = c.start.Add(duration)
+		// waves can ask a light to hold a little longer before its turn
+		if wait := cycle.start.Sub(c.cfg.clock.Now()); wait > 0 {
+			select {
+			case <-ctx.Done():
+				return
+			case <-c.state.Changed():
+			case <-c.cfg.clock.After(wait):
+			}
 
-		ticker := time.NewTicker(duration / time.Duration(c.cfg.steps))
-		timer := time.NewTimer(duration)
+			continue
+		}
+
+		duration := cycle.end.Sub(cycle.start)
+
+		c.mu.Lock()
+		c.next, c.overlay = cycle.target, cycle.overlay
+		c.start = cycle.start
+		c.end = cycle.end
+		c.mu.Unlock()
+
+		ticker := c.cfg.clock.NewTicker(duration / time.Duration(c.cfg.steps))
+		timer := c.cfg.clock.NewTimer(max(c.end.Sub(c.cfg.clock.Now()), 0))
 
 		c.updateColor(topic, duration.Seconds())
 
 		for {
+			// grabbed before checking so a change in between isn't missed
+			changed := c.state.Changed()
+			if !c.active() {
+				// paused or switched off mid transition, remember where we stopped
+				// so resuming picks up from there
+				ticker.Stop()
+				timer.Stop()
+
+				c.mu.Lock()
+				c.previous = c.current()
+				c.mu.Unlock()
+
+				continue outer
+			}
+
 			select {
 			case <-ctx.Done():
 				return
 
-			case <-ticker.C:
-				c.updateColor(topic, duration.Seconds())
+			case <-changed:
+
+			case <-ticker.C():
+				// a pause can land at the same time as a tick
+				if c.active() {
+					c.updateColor(topic, duration.Seconds())
+				}
+
+			case <-timer.C():
+				if !c.active() {
+					continue
+				}
 
-			case <-timer.C:
 				ticker.Stop()
 
-				publish(c.client, topic, 1, false, ColorPayload(c.nextColor, 0))
-				c.previousColor = c.nextColor
+				c.send(topic, c.next, 0)
+
+				c.mu.Lock()
+				c.previous = c.next
+				c.mu.Unlock()
 
 				select {
 				case <-ctx.Done():
 					return
-				case <-time.After(c.cfg.Hold()):
+				case <-c.cfg.clock.After(cycle.until.Sub(c.cfg.clock.Now())):
 					break
 				}
 
@@ -115,17 +246,76 @@ outer:
 	}
 }
 
+// active reports whether the light can be published to. A group command
+// reaches every light in it, so a synced group also holds off while any of its
+// lights is paused, cooling down or switched off.
+func (c *ColorManager) active() bool {
+	if !c.state.Active() {
+		return false
+	}
+
+	for _, member := range c.members {
+		if !member.Active() {
+			return false
+		}
+	}
+
+	return true
+}
+
+// waitActive blocks until the light is not paused. Returns false if the
+// context was cancelled while waiting.
+func (c *ColorManager) waitActive(ctx context.Context) bool {
+	for {
+		changed := c.state.Changed()
+		if c.active() {
+			return true
+		}
+
+		select {
+		case <-ctx.Done():
+			return false
+		case <-changed:
+		}
+	}
+}
+
+// where the light is right now according to the running transition
+func (c *ColorManager) current() Target {
+	total := c.end.Sub(c.start).Seconds()
+	if total <= 0 {
+		return c.next
+	}
+
+	t := clamp01(c.cfg.clock.Now().Sub(c.start).Seconds() / total)
+	return c.previous.Lerp(c.next, t)
+}
+
 func secondsToDuration(seconds float64) time.Duration {
 	nanos := seconds * float64(time.Second)
 	return time.Duration(nanos)
 }
 
 func (c *ColorManager) updateColor(topic string, durationSeconds float64) {
-	transition := min(max(time.Until(c.end), 0), secondsToDuration(durationSeconds/float64(c.cfg.steps)))
+	now := c.cfg.clock.Now()
+	transition := min(max(c.end.Sub(now), 0), secondsToDuration(durationSeconds/float64(c.cfg.steps)))
 
-	elapsed := time.Since(c.start).Seconds()
+	elapsed := now.Sub(c.start).Seconds()
 	t := clamp01((elapsed + transition.Seconds()) / durationSeconds)
 
-	colorStep := c.previousColor.Lerp(c.nextColor, t)
-	publish(c.client, topic, 1, false, ColorPayload(colorStep, transition.Seconds()))
+	step := c.previous.Lerp(c.next, t)
+	c.send(topic, step, transition.Seconds())
+}
+
+func (c *ColorManager) send(topic string, target Target, transition float64) {
+	cmd := NewCommand(target, c.caps, transition)
+	c.state.Published(cmd)
+
+	// members report their own state, so they need to recognise group commands
+	// as ours too
+	for _, member := range c.members {
+		member.Published(cmd)
+	}
+	publish(c.client, topic, 1, false, cmd.Payload())
+	publishesTotal.Inc(c.friendlyName)
 }
@@ -0,0 +1,201 @@
+package main
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	mqtt "github.com/eclipse/paho.mqtt.golang"
+)
+
+// fakeClient records publishes instead of sending them anywhere.
+type fakeClient struct {
+	mqtt.Client
+	published chan fakePublish
+}
+
+type fakePublish struct {
+	topic   string
+	payload string
+}
+
+func newFakeClient() *fakeClient {
+	return &fakeClient{published: make(chan fakePublish, 64)}
+}
+
+func (c *fakeClient) Publish(topic string, qos byte, retained bool, payload any) mqtt.Token {
+	c.published <- fakePublish{topic: topic, payload: string(payload.([]byte))}
+	return doneToken{}
+}
+
+type doneToken struct{ mqtt.Token }
+
+func (doneToken) Wait() bool   { return true }
+func (doneToken) Error() error { return nil }
+
+// next waits for the next publish, failing the test if nothing comes.
+func (c *fakeClient) next(t *testing.T) fakePublish {
+	t.Helper()
+
+	select {
+	case p := <-c.published:
+		return p
+	case <-time.After(time.Second):
+		t.Fatal("expected a publish")
+		return fakePublish{}
+	}
+}
+
+// quiet fails the test if anything is published for a little while.
+func (c *fakeClient) quiet(t *testing.T) {
+	t.Helper()
+
+	select {
+	case p := <-c.published:
+		t.Fatalf("expected no publish, got %s", p.payload)
+	case <-time.After(50 * time.Millisecond):
+	}
+}
+
+func TestColorManagerRunTiming(t *testing.T) {
+	start := time.Date(2025, time.June, 10, 12, 0, 0, 0, time.UTC)
+	clock := NewFakeClock(start)
+
+	first, second := Oklch{L: 0.5, C: 0.1, H: 30}, Oklch{L: 0.7, C: 0.1, H: 200}
+	cfg := RuntimeConfig{
+		clock: clock,
+		steps: 3,
+		ambients: []Palette{{colors: Colors{
+			colors:   []Oklch{first, second},
+			strategy: SelectSequential,
+		}}},
+		transitionMin: 30 * time.Second,
+		transitionMax: 30 * time.Second,
+		holdMin:       30 * time.Second,
+		holdMax:       30 * time.Second,
+	}
+
+	caps := Capabilities{xy: true}
+	client := newFakeClient()
+	cm := &ColorManager{
+		client:       client,
+		friendlyName: "desk",
+		caps:         caps,
+		cfg:          cfg,
+		state:        NewLightState(),
+	}
+	cm.schedule = independentSchedule{cfg: &cm.cfg}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go cm.Run(ctx)
+
+	expect := func(from, to Oklch, t float64, transition float64) string {
+		return string(NewCommand(Target{Color: from.Lerp(to, t)}, caps, transition).Payload())
+	}
+
+	// each step is published as soon as the previous one starts, fading over
+	// a third of the transition
+	for i := 1; i <= 3; i++ {
+		p := client.next(t)
+		if p.topic != "zigbee2mqtt/desk/set" {
+			t.Fatalf("unexpected topic %s", p.topic)
+		}
+
+		if want := expect(first, second, float64(i)/3, 10); p.payload != want {
+			t.Fatalf("step %d: got %s want %s", i, p.payload, want)
+		}
+
+		clock.Advance(10 * time.Second)
+	}
+
+	// the transition ends by settling on the target, possibly twice if the
+	// last tick lands with the end of it
+	if want := expect(first, second, 1, 0); client.next(t).payload != want {
+		t.Fatalf("expected the target to be sent without a transition")
+	}
+
+	drain := func() {
+		for {
+			select {
+			case p := <-client.published:
+				if p.payload != expect(first, second, 1, 0) {
+					t.Fatalf("unexpected publish while holding: %s", p.payload)
+				}
+			case <-time.After(50 * time.Millisecond):
+				return
+			}
+		}
+	}
+	drain()
+
+	// holds for 30s before heading back to the first color
+	clock.Advance(29 * time.Second)
+	client.quiet(t)
+
+	clock.Advance(time.Second)
+	if p, want := client.next(t), expect(second, first, 1.0/3, 10); p.payload != want {
+		t.Fatalf("next cycle: got %s want %s", p.payload, want)
+	}
+
+	if !clock.Now().Equal(start.Add(time.Minute)) {
+		t.Fatalf("unexpected clock %s", clock.Now())
+	}
+}
+
+func TestSyncedGroupHoldsForMembers(t *testing.T) {
+	start := time.Date(2025, time.June, 10, 12, 0, 0, 0, time.UTC)
+	clock := NewFakeClock(start)
+
+	cfg := RuntimeConfig{
+		clock: clock,
+		steps: 3,
+		ambients: []Palette{{colors: Colors{
+			colors:   []Oklch{{L: 0.5, C: 0.1, H: 30}, {L: 0.7, C: 0.1, H: 200}},
+			strategy: SelectSequential,
+		}}},
+		transitionMin: 30 * time.Second,
+		transitionMax: 30 * time.Second,
+		holdMin:       30 * time.Second,
+		holdMax:       30 * time.Second,
+	}
+
+	m := &Manager{}
+	client := newFakeClient()
+
+	m.Lock()
+	m.StartGroup(client, "ceiling", []string{"front", "back"}, Capabilities{xy: true}, cfg)
+	front, back := m.Light("front"), m.Light("back")
+	m.Unlock()
+	defer m.CancelAll()
+
+	if p := client.next(t); p.topic != "zigbee2mqtt/ceiling/set" {
+		t.Fatalf("expected a group publish, got %s", p.topic)
+	}
+
+	// a paused light would be repainted by every group command
+	front.Pause(0)
+	clock.Advance(10 * time.Second)
+	client.quiet(t)
+	clock.Advance(time.Minute)
+	client.quiet(t)
+
+	front.Resume()
+	client.next(t)
+
+	// same for a light that was switched off
+	off, on := "OFF", "ON"
+	back.Observe(Z2MState{State: &off})
+	clock.Advance(10 * time.Second)
+	client.quiet(t)
+
+	back.Observe(Z2MState{State: &on})
+	client.next(t)
+
+	// and a light backing off after being changed by hand
+	back.Cooldown(time.Hour)
+	clock.Advance(10 * time.Second)
+	client.quiet(t)
+	back.Resume()
+	client.next(t)
+}
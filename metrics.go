@@ -0,0 +1,99 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"slices"
+	"strings"
+	"sync"
+)
+
+// Just enough of the prometheus text exposition format to describe lumos,
+// without pulling in the client library.
+
+var (
+	publishesTotal       = NewCounter("lumos_mqtt_publishes_total", "Color updates published per device.", "device")
+	publishFailuresTotal = NewCounter("lumos_mqtt_publish_failures_total", "MQTT publishes that failed, per device.", "device")
+	reconnectsTotal      = NewCounter("lumos_mqtt_reconnects_total", "Times the MQTT client started reconnecting to the broker.", "")
+	refreshesTotal       = NewCounter("lumos_config_refreshes_total", "Times the set of controlled devices was refreshed.", "")
+)
+
+// Counter is a monotonically increasing value, optionally split by a single
+// label. An empty label name makes it a plain counter.
+type Counter struct {
+	name, help, label string
+
+	mu     sync.Mutex
+	values map[string]float64
+}
+
+func NewCounter(name, help, label string) *Counter {
+	return &Counter{
+		name:   name,
+		help:   help,
+		label:  label,
+		values: map[string]float64{},
+	}
+}
+
+func (c *Counter) Inc(labelValue string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.values[labelValue]++
+}
+
+func (c *Counter) write(w io.Writer) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	writeHeader(w, c.name, c.help, "counter")
+
+	if c.label == "" {
+		fmt.Fprintf(w, "%s %g\n", c.name, c.values[""])
+		return
+	}
+
+	keys := make([]string, 0, len(c.values))
+	for key := range c.values {
+		keys = append(keys, key)
+	}
+	slices.Sort(keys)
+
+	for _, key := range keys {
+		fmt.Fprintf(w, "%s{%s=\"%s\"} %g\n", c.name, c.label, escapeLabel(key), c.values[key])
+	}
+}
+
+func writeHeader(w io.Writer, name, help, kind string) {
+	fmt.Fprintf(w, "# HELP %s %s\n", name, help)
+	fmt.Fprintf(w, "# TYPE %s %s\n", name, kind)
+}
+
+var labelEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+
+func escapeLabel(value string) string {
+	return labelEscaper.Replace(value)
+}
+
+func handleMetrics(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4")
+
+	publishesTotal.write(w)
+	publishFailuresTotal.write(w)
+	reconnectsTotal.write(w)
+	refreshesTotal.write(w)
+
+	statuses := lightStatuses()
+
+	writeHeader(w, "lumos_color_managers", "Number of running color managers.", "gauge")
+	fmt.Fprintf(w, "lumos_color_managers %d\n", len(statuses))
+
+	writeHeader(w, "lumos_overlay_mix", "Current mix of each overlay, per device.", "gauge")
+	for _, status := range statuses {
+		for _, overlay := range status.Overlays {
+			fmt.Fprintf(w, "lumos_overlay_mix{device=\"%s\",overlay=\"%s\"} %g\n", escapeLabel(status.FriendlyName), escapeLabel(overlay.Name), overlay.Mix)
+		}
+	}
+}
@@ -0,0 +1,72 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestCounterExposition(t *testing.T) {
+	labelled := NewCounter("lumos_test_total", "A labelled counter.", "device")
+	labelled.Inc("b")
+	labelled.Inc("a \"quoted\"\\path")
+	labelled.Inc("b")
+
+	plain := NewCounter("lumos_plain_total", "A plain counter.", "")
+
+	var out strings.Builder
+	labelled.write(&out)
+	plain.write(&out)
+
+	want := `# HELP lumos_test_total A labelled counter.
+# TYPE lumos_test_total counter
+lumos_test_total{device="a \"quoted\"\\path"} 1
+lumos_test_total{device="b"} 2
+# HELP lumos_plain_total A plain counter.
+# TYPE lumos_plain_total counter
+lumos_plain_total 0
+`
+
+	if out.String() != want {
+		t.Fatalf("unexpected exposition:\n%s\nwant:\n%s", out.String(), want)
+	}
+}
+
+func TestTopicDevice(t *testing.T) {
+	cases := map[string]string{
+		"zigbee2mqtt/desk/set":                   "desk",
+		"zigbee2mqtt/living room/lamp/set":       "living room/lamp",
+		"zigbee2mqtt/bridge/config/devices/get":  "bridge",
+		"zigbee2mqtt/bridge/request/device/ping": "bridge",
+	}
+
+	for topic, want := range cases {
+		if got := topicDevice(topic); got != want {
+			t.Fatalf("%s: got %q want %q", topic, got, want)
+		}
+	}
+}
+
+func TestMetricsEndpoint(t *testing.T) {
+	previous := publishFailuresTotal
+	publishFailuresTotal = NewCounter(previous.name, previous.help, previous.label)
+	t.Cleanup(func() { publishFailuresTotal = previous })
+
+	publishFailuresTotal.Inc(topicDevice("zigbee2mqtt/metrics test/set"))
+
+	recorder := httptest.NewRecorder()
+	handleMetrics(recorder, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+
+	body := recorder.Body.String()
+	for _, line := range []string{
+		"# TYPE lumos_mqtt_publish_failures_total counter",
+		`lumos_mqtt_publish_failures_total{device="metrics test"} 1`,
+		"# TYPE lumos_color_managers gauge",
+		"# TYPE lumos_overlay_mix gauge",
+	} {
+		if !strings.Contains(body, line+"\n") {
+			t.Fatalf("expected %q in metrics:\n%s", line, body)
+		}
+	}
+}
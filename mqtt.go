@@ -5,6 +5,7 @@ import (
 	"encoding/hex"
 	"fmt"
 	"os"
+	"strings"
 	"time"
 
 	"github.com/BSFishy/lumos/util"
@@ -17,6 +18,8 @@ func randSuffix() string {
 	return hex.EncodeToString(b)
 }
 
+var mqttClient mqtt.Client
+
 func SetupMqtt() {
 	broker, ok := os.LookupEnv("MQTT_BROKER")
 	util.Assert(ok, "please specify an mqtt broker")
@@ -38,11 +41,19 @@ func SetupMqtt() {
 	opts.SetPingTimeout(10 * time.Second)
 	opts.SetWriteTimeout(10 * time.Second)
 
+	opts.SetReconnectingHandler(func(mqtt.Client, *mqtt.ClientOptions) {
+		reconnectsTotal.Inc("")
+	})
+
 	// (re)subscribe every time we reconnect
-	// TODO: subscribe to topics to tell lumos to stop updating colors
-	opts.OnConnect = setupGroups
+	opts.OnConnect = func(c mqtt.Client) {
+		setupControl(c)
+		setupState(c)
+		setupGroups(c)
+	}
 
 	c := mqtt.NewClient(opts)
+	mqttClient = c
 	if token := c.Connect(); token.Wait() && token.Error() != nil {
 		panic(token.Error())
 	}
@@ -60,6 +71,23 @@ func publish(c mqtt.Client, topic string, qos byte, retained bool, payload any)
 	token := c.Publish(topic, qos, retained, payload)
 
 	if token.Wait() && token.Error() != nil {
+		publishFailuresTotal.Inc(topicDevice(topic))
 		panic(fmt.Errorf("failed to publish to %s: %w", topic, token.Error()))
 	}
 }
+
+// topicDevice is the friendly name a zigbee2mqtt topic is about, with all the
+// bridge topics counting as "bridge".
+func topicDevice(topic string) string {
+	name := strings.TrimPrefix(topic, "zigbee2mqtt/")
+	if name == topic {
+		return topic
+	}
+
+	name = strings.TrimSuffix(name, "/set")
+	if name == "bridge" || strings.HasPrefix(name, "bridge/") {
+		return "bridge"
+	}
+
+	return name
+}
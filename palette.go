@@ -0,0 +1,218 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"math/rand/v2"
+)
+
+// PaletteColor is an entry in a group's colors. It's either just a color, or
+// an object with one of a color, gradient, hue range or harmony along with an
+// optional weight for weighted selection.
+type PaletteColor struct {
+	Color    Color           `json:"color"`
+	Gradient []Color         `json:"gradient"`
+	HueRange *HueRangeConfig `json:"hue_range"`
+	Harmony  *HarmonyConfig  `json:"harmony"`
+
+	Weight *float64 `json:"weight"`
+
+	// written as a bare string, so errors point at the entry itself
+	bare bool
+}
+
+func (p *PaletteColor) UnmarshalJSON(data []byte) error {
+	if bytes.HasPrefix(bytes.TrimSpace(data), []byte(`"`)) {
+		*p = PaletteColor{bare: true}
+		return json.Unmarshal(data, &p.Color)
+	}
+
+	// keep rejecting typos inside the object like the rest of the config
+	type plain PaletteColor
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.DisallowUnknownFields()
+	return decoder.Decode((*plain)(p))
+}
+
+// ColorSource is one candidate for Colors.Select. Generated sources pick a new
+// color every time they're selected, with color standing in for them in
+// previews and distance comparisons.
+type ColorSource struct {
+	color  Oklch
+	sample func() Oklch
+	weight float64
+}
+
+func (p PaletteColor) Compile() ([]ColorSource, error) {
+	var errs ValidationErrors
+
+	weight := 1.0
+	if p.Weight != nil {
+		weight = collect(&errs, "weight", parseWeight, p.Weight)
+	}
+
+	set := 0
+	for _, ok := range []bool{p.Color != "", p.Gradient != nil, p.HueRange != nil, p.Harmony != nil} {
+		if ok {
+			set++
+		}
+	}
+
+	if set != 1 {
+		errs.Add("", errors.New("expected exactly one of color, gradient, hue_range or harmony"))
+		return nil, errs.Err()
+	}
+
+	var sources []ColorSource
+	switch {
+	case p.Color != "":
+		path := "color"
+		if p.bare {
+			path = ""
+		}
+
+		color := collect(&errs, path, Color.Evaluate, p.Color)
+		sources = []ColorSource{{color: color}}
+	case p.Gradient != nil:
+		sources = collect(&errs, "gradient", compileGradient, p.Gradient)
+	case p.HueRange != nil:
+		sources = collect(&errs, "hue_range", HueRangeConfig.Compile, *p.HueRange)
+	case p.Harmony != nil:
+		sources = collect(&errs, "harmony", HarmonyConfig.Compile, *p.Harmony)
+	}
+
+	// an entry that expands to several colors shares its weight between them
+	for i := range sources {
+		sources[i].weight = weight / float64(len(sources))
+	}
+
+	return sources, errs.Err()
+}
+
+func parseWeight(weight *float64) (float64, error) {
+	if weight == nil {
+		return 1, nil
+	}
+
+	if *weight <= 0 {
+		return 0, fmt.Errorf("must be greater than 0, got %g", *weight)
+	}
+
+	return *weight, nil
+}
+
+// a continuous gradient through the stops, sampled anywhere along it
+func compileGradient(stops []Color) ([]ColorSource, error) {
+	var errs ValidationErrors
+
+	if len(stops) < 2 {
+		errs.Add("", errors.New("needs at least two stops"))
+	}
+
+	colors := make([]Oklch, len(stops))
+	for i, stop := range stops {
+		colors[i] = collect(&errs, fmt.Sprintf("[%d]", i), Color.Evaluate, stop)
+	}
+
+	if err := errs.Err(); err != nil {
+		return nil, err
+	}
+
+	at := func(t float64) Oklch {
+		pos := t * float64(len(colors)-1)
+		i := min(int(pos), len(colors)-2)
+		return colors[i].Lerp(colors[i+1], pos-float64(i))
+	}
+
+	return []ColorSource{{
+		color:  at(0.5),
+		sample: func() Oklch { return at(rand.Float64()) },
+	}}, nil
+}
+
+type HueRangeConfig struct {
+	// hues in degrees, going around the wheel from from to to, so 330 to 30
+	// covers the reds
+	From float64 `json:"from"`
+	To   float64 `json:"to"`
+
+	Lightness string  `json:"lightness"`
+	Chroma    float64 `json:"chroma"`
+}
+
+func (h HueRangeConfig) Compile() ([]ColorSource, error) {
+	var errs ValidationErrors
+
+	for _, hue := range []struct {
+		path  string
+		value float64
+	}{{"from", h.From}, {"to", h.To}} {
+		if hue.value < 0 || hue.value > 360 {
+			errs.Add(hue.path, fmt.Errorf("must be between 0 and 360, got %g", hue.value))
+		}
+	}
+
+	// a percentage or a number from 0 to 1, like lightness everywhere else
+	lightness := collect(&errs, "lightness", number(1), h.Lightness)
+	if h.Chroma < 0 {
+		errs.Add("chroma", fmt.Errorf("must not be negative, got %g", h.Chroma))
+	}
+
+	if err := errs.Err(); err != nil {
+		return nil, err
+	}
+
+	span := math.Mod(h.To-h.From+360, 360)
+	if span == 0 && h.From != h.To {
+		span = 360
+	}
+	at := func(t float64) Oklch {
+		return Oklch{L: lightness, C: h.Chroma, H: math.Mod(h.From+span*t, 360)}
+	}
+
+	return []ColorSource{{
+		color:  at(0.5),
+		sample: func() Oklch { return at(rand.Float64()) },
+	}}, nil
+}
+
+// hue offsets from the base color for each harmony
+var harmonies = map[string][]float64{
+	"analogous":           {0, -30, 30},
+	"complementary":       {0, 180},
+	"split_complementary": {0, 150, 210},
+	"triadic":             {0, 120, 240},
+	"tetradic":            {0, 90, 180, 270},
+}
+
+type HarmonyConfig struct {
+	Base   Color  `json:"base"`
+	Scheme string `json:"scheme"`
+}
+
+// Compile rotates the base color's hue, keeping its lightness and chroma, to
+// get one fixed color per hue in the scheme.
+func (h HarmonyConfig) Compile() ([]ColorSource, error) {
+	var errs ValidationErrors
+
+	base := collect(&errs, "base", Color.Evaluate, h.Base)
+
+	offsets, ok := harmonies[h.Scheme]
+	if !ok {
+		errs.Add("scheme", fmt.Errorf("unknown scheme %q, expected analogous, complementary, split_complementary, triadic or tetradic", h.Scheme))
+	}
+
+	if err := errs.Err(); err != nil {
+		return nil, err
+	}
+
+	sources := make([]ColorSource, len(offsets))
+	for i, offset := range offsets {
+		sources[i] = ColorSource{color: Oklch{L: base.L, C: base.C, H: math.Mod(base.H+offset+360, 360)}}
+	}
+
+	return sources, nil
+}
@@ -0,0 +1,126 @@
+package main
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+func compilePaletteColor(t *testing.T, entry string) []ColorSource {
+	t.Helper()
+
+	var color PaletteColor
+	if err := json.Unmarshal([]byte(entry), &color); err != nil {
+		t.Fatalf("failed to parse %s: %v", entry, err)
+	}
+
+	sources, err := color.Compile()
+	if err != nil {
+		t.Fatalf("failed to compile %s: %v", entry, err)
+	}
+
+	return sources
+}
+
+func TestGradientSamplesBetweenStops(t *testing.T) {
+	sources := compilePaletteColor(t, `{"gradient": ["oklch(20% 0.1 30)", "oklch(60% 0.1 30)", "oklch(80% 0.1 30)"]}`)
+	if len(sources) != 1 || sources[0].sample == nil {
+		t.Fatalf("expected a single sampled source, got %+v", sources)
+	}
+
+	for range 100 {
+		if c := sources[0].sample(); c.L < 0.2-1e-9 || c.L > 0.8+1e-9 {
+			t.Fatalf("sample %v is outside the gradient", c)
+		}
+	}
+}
+
+func TestHueRangeWraps(t *testing.T) {
+	sources := compilePaletteColor(t, `{"hue_range": {"from": 330, "to": 30, "lightness": "0.7", "chroma": 0.12}}`)
+
+	for range 100 {
+		c := sources[0].sample()
+		if c.H > 30 && c.H < 330 {
+			t.Fatalf("hue %g is outside 330 to 30", c.H)
+		}
+
+		if c.L != 0.7 || c.C != 0.12 {
+			t.Fatalf("expected fixed lightness and chroma, got %v", c)
+		}
+	}
+
+	if mid := sources[0].color.H; !almostEqual(mid, 0) && !almostEqual(mid, 360) {
+		t.Fatalf("expected the range to be represented by red, got %g", mid)
+	}
+}
+
+func TestHarmony(t *testing.T) {
+	sources := compilePaletteColor(t, `{"harmony": {"base": "oklch(70% 0.15 40)", "scheme": "triadic"}, "weight": 2}`)
+
+	want := []float64{40, 160, 280}
+	if len(sources) != len(want) {
+		t.Fatalf("expected %d colors, got %d", len(want), len(sources))
+	}
+
+	for i, source := range sources {
+		if !almostEqual(source.color.H, want[i]) || !almostEqual(source.weight, 2.0/3) {
+			t.Fatalf("color %d: expected hue %g with a third of the weight, got %v weight %g", i, want[i], source.color, source.weight)
+		}
+	}
+}
+
+func TestExpandedEntriesKeepTheirWeight(t *testing.T) {
+	weight := 2.0
+	group := GroupConfig{Colors: []PaletteColor{
+		{Harmony: &HarmonyConfig{Base: "oklch(70% 0.15 40)", Scheme: "tetradic"}, Weight: &weight},
+		{Color: "#ff0000", Weight: &weight},
+	}}
+
+	colors := group.CompileColors()
+	if len(colors.colors) != 5 || !almostEqual(colors.Weight(), 4) {
+		t.Fatalf("expected 5 colors weighing 4 in total, got %d weighing %g", len(colors.colors), colors.Weight())
+	}
+
+	// the single color is picked as often as the whole harmony
+	single := 0
+	for range 10000 {
+		if colors.Select(Oklch{}) == colors.colors[4] {
+			single++
+		}
+	}
+
+	if single < 4700 || single > 5300 {
+		t.Fatalf("expected the single color about half the time, got %d of 10000", single)
+	}
+}
+
+func TestDefaultWeightMatchesNoWeight(t *testing.T) {
+	harmony := &HarmonyConfig{Base: "oklch(70% 0.15 40)", Scheme: "triadic"}
+	one := 1.0
+
+	implicit := GroupConfig{Colors: []PaletteColor{{Color: "#ff0000"}, {Harmony: harmony}}}
+	explicit := GroupConfig{Colors: []PaletteColor{{Color: "#ff0000", Weight: &one}, {Harmony: harmony}}}
+
+	for name, group := range map[string]GroupConfig{"implicit": implicit, "explicit": explicit} {
+		colors := group.CompileColors()
+		if colors.strategy != SelectWeighted || !almostEqual(colors.Weight(), 2) || !almostEqual(colors.weights[0], 0.5*colors.Weight()) {
+			t.Fatalf("%s: expected red to be half of a weight of 2, got %s over %v", name, colors.strategy, colors.weights)
+		}
+	}
+
+	// plain colors stay evenly picked either way
+	plain := GroupConfig{Colors: []PaletteColor{{Color: "#ff0000", Weight: &one}, {Color: "#0000ff"}}}
+	if colors := plain.CompileColors(); colors.strategy != "" || colors.Weight() != 2 {
+		t.Fatalf("expected even weights to keep the default selection, got %s over %g", colors.strategy, colors.Weight())
+	}
+}
+
+func TestPaletteColorNeedsOneSource(t *testing.T) {
+	var color PaletteColor
+	if err := json.Unmarshal([]byte(`{"color": "#fff", "gradient": ["#000", "#fff"]}`), &color); err != nil {
+		t.Fatalf("failed to parse: %v", err)
+	}
+
+	if _, err := color.Compile(); err == nil {
+		t.Fatalf("expected a color with a gradient to be rejected")
+	}
+}
@@ -3,25 +3,25 @@ package main
 import (
 	"math/rand/v2"
 	"time"
+
+	"github.com/BSFishy/lumos/util"
 )
 
-type TimedOverlay struct {
-	fadeInStart  time.Time
-	fadeInEnd    time.Time
-	fadeOutStart time.Time
-	fadeOutEnd   time.Time
+type TimeOverlay struct {
+	fadeInStart  TimeOfDay
+	fadeInEnd    TimeOfDay
+	fadeOutStart TimeOfDay
+	fadeOutEnd   TimeOfDay
 }
 
-type TimeOverlay TimedOverlay
-
-func (t *TimeOverlay) Mix() float64 {
-	now := time.Now().In(loc)
+func (t *TimeOverlay) Mix(now time.Time) float64 {
+	now = now.In(loc)
 	n := minutesSinceMidnight(now)
 
-	fiS := minutesSinceMidnight(asToday(now, t.fadeInStart))
-	fiE := minutesSinceMidnight(asToday(now, t.fadeInEnd))
-	foS := minutesSinceMidnight(asToday(now, t.fadeOutStart))
-	foE := minutesSinceMidnight(asToday(now, t.fadeOutEnd))
+	fiS := minutesSinceMidnight(t.fadeInStart.On(now))
+	fiE := minutesSinceMidnight(t.fadeInEnd.On(now))
+	foS := minutesSinceMidnight(t.fadeOutStart.On(now))
+	foE := minutesSinceMidnight(t.fadeOutEnd.On(now))
 
 	// piecewise on the circular day:
 	switch {
@@ -36,19 +36,29 @@ func (t *TimeOverlay) Mix() float64 {
 	}
 }
 
-type DateOverlay TimedOverlay
+type DateOverlay struct {
+	fadeInStart  DayOfYear
+	fadeInEnd    DayOfYear
+	fadeOutStart DayOfYear
+	fadeOutEnd   DayOfYear
+}
 
-func (d *DateOverlay) Mix() float64 {
-	now := time.Now().In(loc)
+func (d *DateOverlay) Mix(now time.Time) float64 {
+	now = now.In(loc)
 	y := now.Year()
 	total := yearLength(y)
 
-	fiS := ydayFromMMDD(y, d.fadeInStart)
-	fiE := ydayFromMMDD(y, d.fadeInEnd)
-	foS := ydayFromMMDD(y, d.fadeOutStart)
-	foE := ydayFromMMDD(y, d.fadeOutEnd)
+	fiS, ok1 := d.fadeInStart.YearDay(y)
+	fiE, ok2 := d.fadeInEnd.YearDay(y)
+	foS, ok3 := d.fadeOutStart.YearDay(y)
+	foE, ok4 := d.fadeOutEnd.YearDay(y)
 	n := now.YearDay() - 1 // 0-based
 
+	// dates listed explicitly might not cover this year
+	if !ok1 || !ok2 || !ok3 || !ok4 {
+		return 0.0
+	}
+
 	switch {
 	case inArcDays(fiS, fiE, n, total): // fading in
 		return fracAlongDays(fiS, fiE, n, total)
@@ -61,33 +71,170 @@ func (d *DateOverlay) Mix() float64 {
 	}
 }
 
+type WeekdayOverlay struct {
+	days    [7]bool
+	fadeIn  time.Duration
+	fadeOut time.Duration
+}
+
+func (w *WeekdayOverlay) Mix(now time.Time) float64 {
+	now = now.In(loc)
+	today := now.Weekday()
+	if w.days[today] {
+		return 1.0
+	}
+
+	y, m, d := now.Date()
+	midnight := time.Date(y, m, d, 0, 0, 0, 0, loc)
+	tomorrow := time.Date(y, m, d+1, 0, 0, 0, 0, loc)
+
+	mix := 0.0
+
+	// fading in ahead of an active tomorrow
+	if w.fadeIn > 0 && w.days[(today+1)%7] {
+		if remaining := tomorrow.Sub(now); remaining < w.fadeIn {
+			mix = max(mix, 1.0-remaining.Seconds()/w.fadeIn.Seconds())
+		}
+	}
+
+	// fading out after an active yesterday
+	if w.fadeOut > 0 && w.days[(today+6)%7] {
+		if since := now.Sub(midnight); since < w.fadeOut {
+			mix = max(mix, 1.0-since.Seconds()/w.fadeOut.Seconds())
+		}
+	}
+
+	return mix
+}
+
+// Target is a point a light transitions from or to.
+type Target struct {
+	Color Oklch
+
+	// 0 to 1, only sent when HasBrightness is set so groups that don't manage
+	// brightness leave it alone
+	Brightness    float64
+	HasBrightness bool
+
+	// how to get here from the previous target, nil for a linear fade in oklch
+	interpolation *Interpolation
+}
+
+// Lerp moves f of the way from t to to, the way to says to get there. Easing
+// shapes the brightness fade along with the color so both arrive together.
+func (t Target) Lerp(to Target, f float64) Target {
+	var color Oklch
+	if to.interpolation != nil {
+		f = to.interpolation.ease(f)
+		color = to.interpolation.Lerp(t.Color, to.Color, f)
+	} else {
+		color = t.Color.Lerp(to.Color, f)
+	}
+
+	out := Target{
+		Color:         color,
+		Brightness:    to.Brightness,
+		HasBrightness: to.HasBrightness,
+		interpolation: to.interpolation,
+	}
+
+	// without a starting brightness there's nothing to fade from, so jump
+	// straight to the target
+	if t.HasBrightness && to.HasBrightness {
+		out.Brightness = t.Brightness + (to.Brightness-t.Brightness)*f
+	}
+
+	return out
+}
+
+type BrightnessMode int
+
+const (
+	// brightness follows each color's Oklch lightness
+	BrightnessFromLightness BrightnessMode = iota
+	// brightness is picked at random for each color
+	BrightnessRandom
+)
+
+type BrightnessRange struct {
+	mode     BrightnessMode
+	min, max float64
+}
+
+func (b *BrightnessRange) For(color Oklch) float64 {
+	switch b.mode {
+	case BrightnessRandom:
+		return b.min + rand.Float64()*(b.max-b.min)
+	default:
+		return min(max(color.L, b.min), b.max)
+	}
+}
+
+// Palette is the colors a single group contributes, along with how that group
+// wants them shown.
+type Palette struct {
+	colors        Colors
+	brightness    *BrightnessRange
+	interpolation *Interpolation
+}
+
+func (p *Palette) Select(current Oklch) Target {
+	color := p.colors.Select(current)
+
+	target := Target{Color: color, interpolation: p.interpolation}
+	if p.brightness != nil {
+		target.Brightness = p.brightness.For(color)
+		target.HasBrightness = true
+	}
+
+	return target
+}
+
 type Overlay struct {
-	colors Colors
-	time   *TimeOverlay
-	date   *DateOverlay
+	name     string
+	palette  Palette
+	time     *TimeOverlay
+	date     *DateOverlay
+	weekday  *WeekdayOverlay
+	calendar *CalendarOverlay
 }
 
-func (o *Overlay) Mix() float64 {
+func (o *Overlay) Mix(now time.Time) float64 {
 	var timeMix float64
 	if o.time == nil {
 		timeMix = 1
 	} else {
-		timeMix = o.time.Mix()
+		timeMix = o.time.Mix(now)
 	}
 
 	var dateMix float64
 	if o.date == nil {
 		dateMix = 1
 	} else {
-		dateMix = o.date.Mix()
+		dateMix = o.date.Mix(now)
 	}
 
-	return timeMix * dateMix
+	var weekdayMix float64
+	if o.weekday == nil {
+		weekdayMix = 1
+	} else {
+		weekdayMix = o.weekday.Mix(now)
+	}
+
+	var calendarMix float64
+	if o.calendar == nil {
+		calendarMix = 1
+	} else {
+		calendarMix = o.calendar.Mix(now)
+	}
+
+	return timeMix * dateMix * weekdayMix * calendarMix
 }
 
 type RuntimeConfig struct {
+	clock    Clock
 	steps    uint
-	ambients Colors
+	ambients []Palette
 	overlays []Overlay
 
 	transitionMin time.Duration
@@ -106,20 +253,60 @@ func (r *RuntimeConfig) Hold() time.Duration {
 	return time.Duration(seconds * float64(time.Second))
 }
 
-func (r *RuntimeConfig) SelectColor() Oklch {
-	for _, overlay := range r.overlays {
+// SelectColor picks the next target for a light currently showing current,
+// along with the overlay it came from. A nil overlay means the color is
+// ambient.
+func (r *RuntimeConfig) SelectColor(current Oklch) (Target, *Overlay) {
+	now := r.clock.Now()
+
+	for i := range r.overlays {
+		overlay := &r.overlays[i]
+
 		// a mix of 1 means this overlay should always take over. rand.Float64()
 		// returns a value [0, 1) so that means if the mix is 1, a random value will
 		// always be less than it. similarly, if the mix is 0, this overlay should
 		// never be used. that means a random value will always be above the mix.
 		// anything in between will randomly select this overlay or move to the next
 		// one.
-		threshold := overlay.Mix()
+		threshold := overlay.Mix(now)
 		r := rand.Float64()
 		if r < threshold {
-			return overlay.colors.Select()
+			return overlay.palette.Select(current), overlay
 		}
 	}
 
-	return r.ambients.Select()
+	return r.selectAmbient(current), nil
+}
+
+// selectAmbient picks from every ambient group's colors as if they were one
+// big list, then lets the group pick within its own palette.
+func (r *RuntimeConfig) selectAmbient(current Oklch) Target {
+	total := 0.0
+	for i := range r.ambients {
+		total += r.ambients[i].colors.Weight()
+	}
+
+	util.Assert(total > 0, "must have ambient colors")
+
+	n := rand.Float64() * total
+	for i := range r.ambients {
+		palette := &r.ambients[i]
+		if weight := palette.colors.Weight(); n < weight || i == len(r.ambients)-1 {
+			return palette.Select(current)
+		}
+
+		n -= palette.colors.Weight()
+	}
+
+	panic("unreachable")
+}
+
+// AmbientColors lists every ambient color across groups.
+func (r *RuntimeConfig) AmbientColors() []Oklch {
+	colors := []Oklch{}
+	for _, palette := range r.ambients {
+		colors = append(colors, palette.colors.colors...)
+	}
+
+	return colors
 }
@@ -0,0 +1,290 @@
+package main
+
+import (
+	"errors"
+	"math"
+	"testing"
+	"time"
+
+	"github.com/BSFishy/lumos/util"
+)
+
+// useLocation swaps the configured location for the duration of a test.
+func useLocation(t *testing.T, name string) *time.Location {
+	t.Helper()
+
+	l, err := time.LoadLocation(name)
+	if err != nil {
+		t.Skipf("timezone data for %s not available: %v", name, err)
+	}
+
+	previous := loc
+	loc = l
+	t.Cleanup(func() { loc = previous })
+
+	return l
+}
+
+func TestTimeOverlayWrapsMidnight(t *testing.T) {
+	l := useLocation(t, "America/Chicago")
+
+	overlay := util.Must(TimeConfig{
+		FadeIn:  TimeFader{Start: "10:00PM", End: "11:00PM"},
+		FadeOut: TimeFader{Start: "1:00AM", End: "2:00AM"},
+	}.Compile(nil))
+
+	cases := []struct {
+		hour, minute int
+		want         float64
+	}{
+		{21, 0, 0},
+		{22, 30, 0.5},
+		{23, 30, 1},
+		{0, 30, 1},
+		{1, 30, 0.5},
+		{3, 0, 0},
+	}
+
+	for _, c := range cases {
+		now := time.Date(2025, time.June, 10, c.hour, c.minute, 0, 0, l)
+		if got := overlay.Mix(now); !almostEqual(got, c.want) {
+			t.Fatalf("mix at %s: got %f want %f", now.Format(time.Kitchen), got, c.want)
+		}
+	}
+}
+
+func TestTimeOverlayAcrossDst(t *testing.T) {
+	l := useLocation(t, "America/Chicago")
+
+	overlay := util.Must(TimeConfig{
+		FadeIn:  TimeFader{Start: "1:00AM", End: "4:00AM"},
+		FadeOut: TimeFader{Start: "8:00PM", End: "9:00PM"},
+	}.Compile(nil))
+
+	// clocks jump from 2:00AM to 3:00AM on 2025-03-09. the fade follows the
+	// wall clock, so 3:30AM is 150 of 180 wall clock minutes in.
+	now := time.Date(2025, time.March, 9, 3, 30, 0, 0, l)
+	if got := overlay.Mix(now); !almostEqual(got, 150.0/180.0) {
+		t.Fatalf("spring forward: got %f", got)
+	}
+
+	// and back from 2:00AM to 1:00AM on 2025-11-02. the second 1:30AM is
+	// still 30 wall clock minutes in.
+	now = time.Date(2025, time.November, 2, 1, 30, 0, 0, l).Add(time.Hour)
+	if now.Hour() != 1 {
+		t.Fatalf("expected the repeated 1AM hour, got %s", now)
+	}
+
+	if got := overlay.Mix(now); !almostEqual(got, 30.0/180.0) {
+		t.Fatalf("fall back: got %f", got)
+	}
+}
+
+func TestDateOverlayWrapsYear(t *testing.T) {
+	l := useLocation(t, "America/Chicago")
+
+	overlay := util.Must(SeasonalConfig{
+		FadeIn:  DateFader{Start: "12-01", End: "12-11"},
+		FadeOut: DateFader{Start: "01-10", End: "01-20"},
+	}.Compile())
+
+	cases := []struct {
+		month time.Month
+		day   int
+		want  float64
+	}{
+		{time.November, 20, 0},
+		{time.December, 6, 0.5},
+		{time.December, 25, 1},
+		{time.January, 1, 1},
+		{time.January, 15, 0.5},
+		{time.March, 1, 0},
+	}
+
+	for _, c := range cases {
+		now := time.Date(2025, c.month, c.day, 12, 0, 0, 0, l)
+		if got := overlay.Mix(now); !almostEqual(got, c.want) {
+			t.Fatalf("mix on %s: got %f want %f", now.Format(dayLayout), got, c.want)
+		}
+	}
+}
+
+func TestDateOverlayLeapYear(t *testing.T) {
+	l := useLocation(t, "America/Chicago")
+
+	overlay := util.Must(SeasonalConfig{
+		FadeIn:  DateFader{Start: "02-28", End: "03-01"},
+		FadeOut: DateFader{Start: "06-01", End: "06-02"},
+	}.Compile())
+
+	// feb 29 sits halfway through the two day fade
+	leap := time.Date(2024, time.February, 29, 12, 0, 0, 0, l)
+	if got := overlay.Mix(leap); !almostEqual(got, 0.5) {
+		t.Fatalf("leap day: got %f", got)
+	}
+
+	// without it the fade is a single day, so feb 28 is its start
+	common := time.Date(2025, time.February, 28, 12, 0, 0, 0, l)
+	if got := overlay.Mix(common); !almostEqual(got, 0) {
+		t.Fatalf("common year: got %f", got)
+	}
+
+	if got := overlay.Mix(time.Date(2025, time.March, 1, 12, 0, 0, 0, l)); !almostEqual(got, 1) {
+		t.Fatalf("common year fade end: got %f", got)
+	}
+}
+
+func TestSelectColorUsesClock(t *testing.T) {
+	l := useLocation(t, "America/Chicago")
+
+	clock := NewFakeClock(time.Date(2025, time.June, 10, 12, 0, 0, 0, l))
+	overlay := util.Must(TimeConfig{
+		FadeIn:  TimeFader{Start: "6:00PM", End: "6:00PM"},
+		FadeOut: TimeFader{Start: "7:00PM", End: "7:00PM"},
+	}.Compile(nil))
+
+	ambient := Oklch{L: 0.5}
+	evening := Oklch{L: 0.9}
+	cfg := RuntimeConfig{
+		clock:    clock,
+		ambients: []Palette{{colors: Colors{colors: []Oklch{ambient, ambient}}}},
+		overlays: []Overlay{{
+			time:    overlay,
+			palette: Palette{colors: Colors{colors: []Oklch{evening, evening}}},
+		}},
+	}
+
+	if got, o := cfg.SelectColor(Oklch{}); got.Color != ambient || o != nil {
+		t.Fatalf("midday: got %v from %v", got, o)
+	}
+
+	clock.Advance(6*time.Hour + 30*time.Minute)
+	if got, o := cfg.SelectColor(Oklch{}); got.Color != evening || o == nil {
+		t.Fatalf("evening: got %v from %v", got, o)
+	}
+}
+
+func TestWeekdayOverlayFadesAcrossMidnight(t *testing.T) {
+	l := useLocation(t, "America/Chicago")
+
+	overlay := util.Must(WeekdayConfig{
+		Days:    []string{"weekend"},
+		FadeIn:  "4h",
+		FadeOut: "2h",
+	}.Compile())
+
+	cases := []struct {
+		date time.Time
+		want float64
+	}{
+		{time.Date(2025, time.June, 13, 12, 0, 0, 0, l), 0},   // friday noon
+		{time.Date(2025, time.June, 13, 22, 0, 0, 0, l), 0.5}, // friday 10PM
+		{time.Date(2025, time.June, 14, 12, 0, 0, 0, l), 1},   // saturday
+		{time.Date(2025, time.June, 15, 23, 0, 0, 0, l), 1},   // sunday night
+		{time.Date(2025, time.June, 16, 1, 0, 0, 0, l), 0.5},  // monday 1AM
+		{time.Date(2025, time.June, 16, 3, 0, 0, 0, l), 0},    // monday 3AM
+	}
+
+	for _, c := range cases {
+		if got := overlay.Mix(c.date); !almostEqual(got, c.want) {
+			t.Fatalf("mix on %s: got %f want %f", c.date.Format(time.RFC1123), got, c.want)
+		}
+	}
+}
+
+func TestBrightnessRange(t *testing.T) {
+	brightness := util.Must(BrightnessConfig{Min: 127, Max: 254}.Compile())
+
+	palette := Palette{
+		colors:     Colors{colors: []Oklch{{L: 0.2}, {L: 0.2}}},
+		brightness: brightness,
+	}
+
+	got := palette.Select(Oklch{})
+	if !got.HasBrightness || !almostEqual(got.Brightness, 127.0/254) {
+		t.Fatalf("dark color should be capped at the minimum, got %+v", got)
+	}
+
+	from := Target{Color: Oklch{L: 0.5}, Brightness: 0.5, HasBrightness: true}
+	to := Target{Color: Oklch{L: 0.5}, Brightness: 1, HasBrightness: true}
+	if mid := from.Lerp(to, 0.5); !almostEqual(mid.Brightness, 0.75) {
+		t.Fatalf("expected brightness to interpolate, got %g", mid.Brightness)
+	}
+
+	if _, err := (BrightnessConfig{Min: 200, Max: 100}).Compile(); err == nil {
+		t.Fatalf("expected min above max to be rejected")
+	}
+}
+
+func TestColorSelectionStrategies(t *testing.T) {
+	a, b, c := Oklch{L: 0.2}, Oklch{L: 0.5, C: 0.1, H: 120}, Oklch{L: 0.9}
+	palette := []Oklch{a, b, c}
+
+	single := Colors{colors: []Oklch{a}}
+	if got := single.Select(a); got != a {
+		t.Fatalf("single color: got %v", got)
+	}
+
+	uniform := Colors{colors: palette}
+	previous := uniform.Select(Oklch{})
+	for range 100 {
+		next := uniform.Select(previous)
+		if next == previous {
+			t.Fatalf("uniform picked %v twice in a row", next)
+		}
+
+		previous = next
+	}
+
+	sequential := Colors{colors: palette, strategy: SelectSequential}
+	for i := range 6 {
+		if got := sequential.Select(Oklch{}); got != palette[i%3] {
+			t.Fatalf("sequential pick %d: got %v", i, got)
+		}
+	}
+
+	bag := Colors{colors: palette, strategy: SelectShuffleBag}
+	for round := range 10 {
+		seen := map[Oklch]bool{}
+		for range 3 {
+			seen[bag.Select(Oklch{})] = true
+		}
+
+		if len(seen) != 3 {
+			t.Fatalf("shuffle bag round %d repeated a color: %v", round, seen)
+		}
+	}
+
+	weighted := Colors{colors: palette, weights: []float64{1, 3, 6}, strategy: SelectWeighted}
+	counts := map[Oklch]float64{}
+	for range 10000 {
+		counts[weighted.Select(Oklch{})]++
+	}
+
+	for i, want := range []float64{0.1, 0.3, 0.6} {
+		if got := counts[palette[i]] / 10000; math.Abs(got-want) > 0.03 {
+			t.Fatalf("weighted: expected %v picked %.0f%% of the time, got %.1f%%", palette[i], want*100, got*100)
+		}
+	}
+
+	furthest := Colors{colors: palette, strategy: SelectMaxDistance}
+	if got := furthest.Select(a); got != c {
+		t.Fatalf("max distance from %v: got %v", a, got)
+	}
+}
+
+func TestWeightsImplyWeightedSelection(t *testing.T) {
+	weight := 3.0
+	group := GroupConfig{Colors: []PaletteColor{{Color: "#ff0000", Weight: &weight}, {Color: "#0000ff"}}}
+
+	if colors := group.CompileColors(); colors.strategy != SelectWeighted || colors.Weight() != 4 {
+		t.Fatalf("expected weighted selection over a weight of 4, got %s over %g", colors.strategy, colors.Weight())
+	}
+
+	group.Selection = SelectShuffleBag
+
+	var errs ValidationErrors
+	if err := group.Validate(nil); !errors.As(err, &errs) || len(errs) != 1 || errs[0].Path != "colors[0].weight" {
+		t.Fatalf("expected weights to be rejected with a shuffle bag, got %v", err)
+	}
+}
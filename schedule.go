@@ -0,0 +1,147 @@
+package main
+
+import (
+	"sync"
+	"time"
+)
+
+// Cycle is one transition to a new target, followed by holding it.
+type Cycle struct {
+	target  Target
+	overlay *Overlay
+
+	start, end time.Time
+
+	// when to move on to the next cycle
+	until time.Time
+}
+
+// Schedule decides what a ColorManager does next.
+type Schedule interface {
+	// Initial is where the light is assumed to start from.
+	Initial(now time.Time) Target
+
+	// Next is the cycle to run at now, moving on from current. It may start in
+	// the future, in which case the light holds until then.
+	Next(now time.Time, current Target) Cycle
+}
+
+// every light picks its own colors and timing
+type independentSchedule struct {
+	cfg *RuntimeConfig
+}
+
+func (s independentSchedule) Initial(now time.Time) Target {
+	target, _ := s.cfg.SelectColor(Oklch{})
+	return target
+}
+
+func (s independentSchedule) Next(now time.Time, current Target) Cycle {
+	transition := s.cfg.Transition()
+	target, overlay := s.cfg.SelectColor(current.Color)
+
+	end := now.Add(transition)
+	return Cycle{
+		target:  target,
+		overlay: overlay,
+		start:   now,
+		end:     end,
+		until:   end.Add(s.cfg.Hold()),
+	}
+}
+
+// Wave is a timeline of cycles shared by an ordered row of lights, each
+// running it a little later than the one before so colors travel along the
+// row.
+type Wave struct {
+	mu  sync.Mutex
+	cfg RuntimeConfig
+
+	// how far behind the last light runs, so cycles it still needs are kept
+	lag time.Duration
+
+	initial Target
+	cycles  []Cycle
+}
+
+func NewWave(cfg RuntimeConfig, lag time.Duration) *Wave {
+	initial, _ := cfg.SelectColor(Oklch{})
+
+	return &Wave{
+		cfg:     cfg,
+		lag:     lag,
+		initial: initial,
+	}
+}
+
+// at returns the cycle running at t on the wave's own timeline, along with the
+// target before it and the cycle after it.
+func (w *Wave) at(t time.Time) (Target, Cycle, Cycle) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	schedule := independentSchedule{cfg: &w.cfg}
+	if len(w.cycles) == 0 {
+		w.cycles = append(w.cycles, schedule.Next(t, w.initial))
+	}
+
+	// forget cycles even the last light has moved past
+	cutoff := w.cfg.clock.Now().Add(-w.lag)
+	for len(w.cycles) > 2 && w.cycles[0].until.Before(cutoff) && w.cycles[0].until.Before(t) {
+		w.initial = w.cycles[0].target
+		w.cycles = w.cycles[1:]
+	}
+
+	// make sure there's a cycle after the one running at t
+	for len(w.cycles) < 2 || !w.cycles[len(w.cycles)-2].until.After(t) {
+		// jump ahead rather than filling in every cycle since, in case
+		// everything was paused for a long time
+		last := w.cycles[len(w.cycles)-1]
+		start := last.until
+		if start.Before(t) {
+			start = t
+		}
+
+		w.cycles = append(w.cycles, schedule.Next(start, last.target))
+	}
+
+	for i := range w.cycles {
+		if w.cycles[i].until.After(t) {
+			previous := w.initial
+			if i > 0 {
+				previous = w.cycles[i-1].target
+			}
+
+			return previous, w.cycles[i], w.cycles[i+1]
+		}
+	}
+
+	panic("unreachable")
+}
+
+// one light in a wave, running the timeline delay behind
+type waveSchedule struct {
+	wave  *Wave
+	delay time.Duration
+}
+
+func (s waveSchedule) Initial(now time.Time) Target {
+	previous, _, _ := s.wave.at(now.Add(-s.delay))
+	return previous
+}
+
+// the wave decides where each color goes, so current doesn't matter here
+func (s waveSchedule) Next(now time.Time, _ Target) Cycle {
+	_, current, next := s.wave.at(now.Add(-s.delay))
+
+	// already holding this cycle's target, so wait for the next one
+	cycle := current
+	if !now.Add(-s.delay).Before(current.end) {
+		cycle = next
+	}
+
+	cycle.start = cycle.start.Add(s.delay)
+	cycle.end = cycle.end.Add(s.delay)
+	cycle.until = cycle.until.Add(s.delay)
+	return cycle
+}
@@ -0,0 +1,85 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+func TestWaveOffsetsLights(t *testing.T) {
+	start := time.Date(2025, time.June, 10, 12, 0, 0, 0, time.UTC)
+	clock := NewFakeClock(start)
+
+	cfg := RuntimeConfig{
+		clock: clock,
+		steps: 5,
+		ambients: []Palette{{colors: Colors{colors: []Oklch{
+			{L: 0.2}, {L: 0.4}, {L: 0.6}, {L: 0.8},
+		}}}},
+		transitionMin: 30 * time.Second,
+		transitionMax: 30 * time.Second,
+		holdMin:       time.Minute,
+		holdMax:       time.Minute,
+	}
+
+	wave := NewWave(cfg, 10*time.Second)
+	first := waveSchedule{wave: wave}
+	second := waveSchedule{wave: wave, delay: 10 * time.Second}
+
+	if first.Initial(start) != second.Initial(start) {
+		t.Fatalf("expected both lights to start from the same color")
+	}
+
+	for range 5 {
+		now := clock.Now()
+		lead := first.Next(now, Target{})
+		follow := second.Next(now, Target{})
+
+		if lead.target != follow.target {
+			t.Fatalf("expected the same target, got %v and %v", lead.target, follow.target)
+		}
+
+		if follow.start.Sub(lead.start) != 10*time.Second || follow.until.Sub(lead.until) != 10*time.Second {
+			t.Fatalf("expected the second light to run 10s behind, got %s", follow.start.Sub(lead.start))
+		}
+
+		clock.Set(lead.until)
+	}
+}
+
+func TestWaveColorsStayInTheWave(t *testing.T) {
+	cfg, err := ParseConfig([]byte(`{
+		"steps": 5,
+		"transition": {"min": "1s", "max": "2s"},
+		"hold": {"min": "0s", "max": "1s"},
+		"groups": [
+			{"colors": ["#ff0000"], "applies_to": ["kitchen"]},
+			{
+				"colors": ["#0000ff"],
+				"applies_to": ["kitchen"],
+				"mode": "wave",
+				"order": ["strip 1", "strip 2"],
+				"offset": "2s"
+			},
+			{"colors": ["#00ff00"], "mode": "wave", "order": ["porch"], "offset": "0s"}
+		]
+	}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// other lights in the kitchen don't pick up the wave's colors
+	kitchen := cfg.Compile([]string{"kitchen"})
+	if colors := kitchen.AmbientColors(); len(colors) != 1 {
+		t.Fatalf("expected only the kitchen's own color, got %v", colors)
+	}
+
+	// and a wave that applies to everything doesn't pull every group in
+	if cfg.ContainsGroup("hallway") {
+		t.Fatal("expected the hallway not to be controlled")
+	}
+
+	wave, offset := cfg.CompileWave(1)
+	if colors := wave.cfg.AmbientColors(); len(colors) != 2 || offset != 2*time.Second {
+		t.Fatalf("expected the wave to run its own and the kitchen's colors, got %v", colors)
+	}
+}
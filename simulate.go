@@ -0,0 +1,243 @@
+package main
+
+import (
+	"encoding/csv"
+	"errors"
+	"flag"
+	"fmt"
+	"image"
+	"image/color"
+	"image/png"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// height of each group's strip in a rendered png
+const stripHeight = 48
+
+// Sample is the color a light would show at a point in time.
+type Sample struct {
+	At     time.Time
+	Target Target
+}
+
+// Simulate drives a RuntimeConfig against a virtual clock from from until to,
+// the same way a ColorManager would, and samples the color every step.
+func Simulate(cfg RuntimeConfig, from, to time.Time, step time.Duration) []Sample {
+	samples := []Sample{}
+
+	clock := NewFakeClock(from)
+	cfg.clock = clock
+
+	previous, _ := cfg.SelectColor(Oklch{})
+	now := from
+	at := from
+
+	for at.Before(to) {
+		clock.Set(now)
+
+		next, _ := cfg.SelectColor(previous.Color)
+		transition := cfg.Transition()
+		hold := cfg.Hold()
+
+		start := now
+		end := start.Add(transition)
+		now = end.Add(hold)
+
+		// guard against a zero length cycle spinning forever
+		if !now.After(start) {
+			now = start.Add(step)
+		}
+
+		for ; at.Before(now) && at.Before(to); at = at.Add(step) {
+			t := 1.0
+			if transition > 0 {
+				t = clamp01(at.Sub(start).Seconds() / transition.Seconds())
+			}
+
+			samples = append(samples, Sample{At: at, Target: previous.Lerp(next, t)})
+		}
+
+		previous = next
+	}
+
+	return samples
+}
+
+type simulation struct {
+	group   string
+	samples []Sample
+}
+
+func runSimulate(args []string) error {
+	var groups []string
+	var out string
+	duration := 24 * time.Hour
+	step := time.Duration(0)
+
+	now := time.Now().In(loc)
+	from := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, loc)
+
+	fs := flag.NewFlagSet("simulate", flag.ContinueOnError)
+	fs.Func("group", "zigbee2mqtt group to simulate, can be repeated. defaults to every group in the config", func(s string) error {
+		groups = append(groups, s)
+		return nil
+	})
+	fs.Func("from", "time to start at, defaults to midnight today", func(s string) error {
+		t, err := parsePreviewTime(s)
+		if err != nil {
+			return err
+		}
+
+		from = t
+		return nil
+	})
+	fs.StringVar(&out, "out", "", "file to write, either .png or .csv")
+	fs.DurationVar(&duration, "duration", duration, "how long to simulate")
+	fs.DurationVar(&step, "step", step, "time between samples, defaults to 1440 samples over the duration")
+
+	path, err := parseArgs(fs, args)
+	if err != nil {
+		return err
+	}
+
+	if out == "" || duration <= 0 {
+		return errUsage
+	}
+
+	if step <= 0 {
+		step = max(duration/1440, time.Second)
+	}
+
+	cfg, err := loadConfigFile(path)
+	if err != nil {
+		return err
+	}
+
+	if len(groups) == 0 {
+		groups = cfg.GroupNames()
+	}
+
+	to := from.Add(duration)
+	simulations := make([]simulation, len(groups))
+	for i, group := range groups {
+		runtime := cfg.Compile([]string{group})
+		if len(runtime.AmbientColors()) == 0 {
+			return fmt.Errorf("group %q has no ambient colors to fall back on", group)
+		}
+
+		simulations[i] = simulation{
+			group:   group,
+			samples: Simulate(runtime, from, to, step),
+		}
+	}
+
+	switch strings.ToLower(filepath.Ext(out)) {
+	case ".png":
+		err = writeSimulationPng(out, simulations)
+	case ".csv":
+		err = writeSimulationCsv(out, simulations)
+	default:
+		return errors.New("--out must end in .png or .csv")
+	}
+
+	if err != nil {
+		return err
+	}
+
+	fmt.Printf("simulated %d groups from %s to %s, written to %s\n", len(groups), from.Format(time.RFC3339), to.Format(time.RFC3339), out)
+	return nil
+}
+
+// GroupNames lists every group referenced by applies_to, or a single empty
+// name when every group applies to all devices.
+func (c *Config) GroupNames() []string {
+	names := []string{}
+	for _, group := range c.Groups {
+		for _, name := range group.AppliesTo {
+			if !slices.Contains(names, name) {
+				names = append(names, name)
+			}
+		}
+	}
+
+	if len(names) == 0 {
+		names = append(names, "")
+	}
+
+	return names
+}
+
+// one horizontal strip per group, one pixel column per sample
+func writeSimulationPng(path string, simulations []simulation) error {
+	width := 0
+	for _, sim := range simulations {
+		width = max(width, len(sim.samples))
+	}
+
+	img := image.NewRGBA(image.Rect(0, 0, width, stripHeight*len(simulations)))
+	for row, sim := range simulations {
+		for x, sample := range sim.samples {
+			r, g, b := sample.Target.Color.ToSRGB()
+			c := color.RGBA{R: to8Bit(r), G: to8Bit(g), B: to8Bit(b), A: 255}
+
+			for y := row * stripHeight; y < (row+1)*stripHeight; y++ {
+				img.SetRGBA(x, y, c)
+			}
+		}
+	}
+
+	f, err := os.Create(path)
+	if err != nil {
+		return fmt.Errorf("failed to create %s: %w", path, err)
+	}
+	defer f.Close()
+
+	if err := png.Encode(f, img); err != nil {
+		return fmt.Errorf("failed to encode png: %w", err)
+	}
+
+	return f.Close()
+}
+
+func writeSimulationCsv(path string, simulations []simulation) error {
+	f, err := os.Create(path)
+	if err != nil {
+		return fmt.Errorf("failed to create %s: %w", path, err)
+	}
+	defer f.Close()
+
+	w := csv.NewWriter(f)
+	_ = w.Write([]string{"group", "timestamp", "x", "y", "hex", "brightness"})
+
+	for _, sim := range simulations {
+		for _, sample := range sim.samples {
+			x, y := sample.Target.Color.ToXY()
+
+			brightness := ""
+			if sample.Target.HasBrightness {
+				brightness = strconv.Itoa(toBrightness(sample.Target.Brightness))
+			}
+
+			_ = w.Write([]string{
+				sim.group,
+				sample.At.Format(time.RFC3339),
+				strconv.FormatFloat(x, 'f', 4, 64),
+				strconv.FormatFloat(y, 'f', 4, 64),
+				sample.Target.Color.Hex(),
+				brightness,
+			})
+		}
+	}
+
+	w.Flush()
+	if err := w.Error(); err != nil {
+		return fmt.Errorf("failed to write csv: %w", err)
+	}
+
+	return f.Close()
+}
@@ -0,0 +1,55 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+func TestSimulateSampleTiming(t *testing.T) {
+	from := time.Date(2025, time.June, 10, 12, 0, 0, 0, time.UTC)
+
+	cfg := RuntimeConfig{
+		steps: 5,
+		ambients: []Palette{{colors: Colors{
+			colors:   []Oklch{{L: 0.2}, {L: 0.8}},
+			strategy: SelectSequential,
+		}}},
+		transitionMin: 30 * time.Second,
+		transitionMax: 30 * time.Second,
+		holdMin:       30 * time.Second,
+		holdMax:       30 * time.Second,
+	}
+
+	samples := Simulate(cfg, from, from.Add(2*time.Minute), 10*time.Second)
+	if len(samples) != 12 {
+		t.Fatalf("expected 12 samples, got %d", len(samples))
+	}
+
+	// starts at the first color, fades to the second over 30s, holds it for
+	// 30s, then heads back
+	want := []float64{0.2, 0.4, 0.6, 0.8, 0.8, 0.8, 0.8, 0.6, 0.4, 0.2, 0.2, 0.2}
+	for i, sample := range samples {
+		if at := from.Add(time.Duration(i) * 10 * time.Second); !sample.At.Equal(at) {
+			t.Fatalf("sample %d: got time %s want %s", i, sample.At, at)
+		}
+
+		if !almostEqual(sample.Target.Color.L, want[i]) {
+			t.Fatalf("sample %d: got lightness %f want %f", i, sample.Target.Color.L, want[i])
+		}
+	}
+}
+
+func TestSimulateInstantCycles(t *testing.T) {
+	from := time.Date(2025, time.June, 10, 12, 0, 0, 0, time.UTC)
+
+	cfg := RuntimeConfig{
+		steps:    5,
+		ambients: []Palette{{colors: Colors{colors: []Oklch{{L: 0.5}}}}},
+	}
+
+	// zero length transitions and holds still move time along
+	samples := Simulate(cfg, from, from.Add(time.Minute), 15*time.Second)
+	if len(samples) != 4 {
+		t.Fatalf("expected 4 samples, got %d", len(samples))
+	}
+}
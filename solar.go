@@ -0,0 +1,152 @@
+package main
+
+import (
+	"fmt"
+	"math"
+	"strings"
+	"time"
+)
+
+// Coordinates of the house, used to work out when solar events happen.
+type Coordinates struct {
+	Latitude  float64 `json:"latitude"`
+	Longitude float64 `json:"longitude"`
+}
+
+func (c *Coordinates) Validate() error {
+	var errs ValidationErrors
+
+	if c.Latitude < -90 || c.Latitude > 90 {
+		errs.Add("latitude", fmt.Errorf("must be between -90 and 90, got %g", c.Latitude))
+	}
+
+	if c.Longitude < -180 || c.Longitude > 180 {
+		errs.Add("longitude", fmt.Errorf("must be between -180 and 180, got %g", c.Longitude))
+	}
+
+	return errs.Err()
+}
+
+type SolarEvent string
+
+// zenith angle, in degrees, the sun is at for each event, and whether it
+// happens in the morning or evening
+var solarEvents = map[SolarEvent]struct {
+	zenith float64
+	rising bool
+}{
+	"sunrise":           {zenith: 90.833, rising: true},
+	"sunset":            {zenith: 90.833, rising: false},
+	"civil_dawn":        {zenith: 96, rising: true},
+	"civil_dusk":        {zenith: 96, rising: false},
+	"nautical_dawn":     {zenith: 102, rising: true},
+	"nautical_dusk":     {zenith: 102, rising: false},
+	"astronomical_dawn": {zenith: 108, rising: true},
+	"astronomical_dusk": {zenith: 108, rising: false},
+}
+
+const solarNoon SolarEvent = "solar_noon"
+
+// TimeOfDay is a point in the day, either a fixed wall clock time or an offset
+// from a solar event that moves throughout the year.
+type TimeOfDay struct {
+	clock time.Time
+
+	event    SolarEvent
+	offset   time.Duration
+	location *Coordinates
+}
+
+// parseTimeOfDay accepts either a time like 3:04PM or a solar event with an
+// optional offset, like sunset-30m, civil_dusk or sunrise+1h.
+func parseTimeOfDay(s string, location *Coordinates) (TimeOfDay, error) {
+	if clock, err := parseClock(s); err == nil {
+		return TimeOfDay{clock: clock}, nil
+	}
+
+	name, offset := s, ""
+	if idx := strings.IndexAny(s, "+-"); idx >= 0 {
+		name, offset = s[:idx], s[idx:]
+	}
+
+	event := SolarEvent(name)
+	if _, ok := solarEvents[event]; !ok && event != solarNoon {
+		return TimeOfDay{}, fmt.Errorf("invalid time %q, expected a time like 3:04PM or a solar event like sunset-30m", s)
+	}
+
+	if location == nil {
+		return TimeOfDay{}, fmt.Errorf("%q is relative to the sun, which needs a location to be configured", s)
+	}
+
+	var d time.Duration
+	if offset != "" {
+		var err error
+		d, err = time.ParseDuration(offset)
+		if err != nil {
+			return TimeOfDay{}, fmt.Errorf("invalid offset %q in %q", offset, s)
+		}
+	}
+
+	return TimeOfDay{event: event, offset: d, location: location}, nil
+}
+
+// On returns when this time of day happens on the same local day as now.
+func (t TimeOfDay) On(now time.Time) time.Time {
+	if t.event == "" {
+		return asToday(now, t.clock)
+	}
+
+	return t.location.SolarTime(now, t.event).Add(t.offset)
+}
+
+// SolarTime works out when an event happens on the local day of date, using
+// the NOAA approximation. It's good to within a minute or two, which is plenty
+// for fading lights.
+//
+// When the event doesn't happen at all (polar day or night), dawn and dusk
+// fall back to whichever of solar noon or midnight the sun is closest to the
+// horizon at.
+func (c *Coordinates) SolarTime(date time.Time, event SolarEvent) time.Time {
+	date = date.In(loc)
+	y, m, d := date.Date()
+	midnight := time.Date(y, m, d, 0, 0, 0, 0, time.UTC)
+
+	// fractional year, in radians, at noon
+	gamma := 2 * math.Pi / float64(yearLength(y)) * float64(date.YearDay()-1)
+
+	// equation of time, in minutes, and solar declination, in radians
+	eqTime := 229.18 * (0.000075 + 0.001868*math.Cos(gamma) - 0.032077*math.Sin(gamma) -
+		0.014615*math.Cos(2*gamma) - 0.040849*math.Sin(2*gamma))
+	decl := 0.006918 - 0.399912*math.Cos(gamma) + 0.070257*math.Sin(gamma) -
+		0.006758*math.Cos(2*gamma) + 0.000907*math.Sin(2*gamma) -
+		0.002697*math.Cos(3*gamma) + 0.00148*math.Sin(3*gamma)
+
+	noon := 720 - 4*c.Longitude - eqTime
+	minutes := noon
+
+	if info, ok := solarEvents[event]; ok {
+		lat := c.Latitude * math.Pi / 180
+		zenith := info.zenith * math.Pi / 180
+
+		cosHA := math.Cos(zenith)/(math.Cos(lat)*math.Cos(decl)) - math.Tan(lat)*math.Tan(decl)
+
+		var ha float64
+		switch {
+		case cosHA > 1: // sun never gets this high, closest is noon
+			ha = 0
+		case cosHA < -1: // sun never gets this low, closest is midnight
+			ha = math.Pi
+		default:
+			ha = math.Acos(cosHA)
+		}
+
+		haMinutes := 4 * ha * 180 / math.Pi
+		if info.rising {
+			minutes = noon - haMinutes
+		} else {
+			minutes = noon + haMinutes
+		}
+	}
+
+	return midnight.Add(time.Duration(minutes * float64(time.Minute))).In(loc)
+}
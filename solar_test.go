@@ -0,0 +1,64 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+func TestSolarTime(t *testing.T) {
+	l := useLocation(t, "America/Chicago")
+	chicago := &Coordinates{Latitude: 41.8781, Longitude: -87.6298}
+
+	cases := []struct {
+		date         time.Time
+		event        SolarEvent
+		hour, minute int
+	}{
+		{time.Date(2025, time.June, 21, 0, 0, 0, 0, l), "sunrise", 5, 15},
+		{time.Date(2025, time.June, 21, 0, 0, 0, 0, l), "sunset", 20, 29},
+		{time.Date(2025, time.December, 21, 0, 0, 0, 0, l), "sunrise", 7, 15},
+		{time.Date(2025, time.December, 21, 0, 0, 0, 0, l), "sunset", 16, 22},
+		{time.Date(2025, time.December, 21, 0, 0, 0, 0, l), "civil_dusk", 16, 52},
+	}
+
+	for _, c := range cases {
+		got := chicago.SolarTime(c.date, c.event)
+		want := time.Date(c.date.Year(), c.date.Month(), c.date.Day(), c.hour, c.minute, 0, 0, l)
+
+		if diff := got.Sub(want).Abs(); diff > 3*time.Minute {
+			t.Fatalf("%s on %s: got %s want %s", c.event, c.date.Format(time.DateOnly), got.Format(time.Kitchen), want.Format(time.Kitchen))
+		}
+	}
+}
+
+func TestTimeOverlayRelativeToSunset(t *testing.T) {
+	l := useLocation(t, "America/Chicago")
+	chicago := &Coordinates{Latitude: 41.8781, Longitude: -87.6298}
+
+	overlay, err := TimeConfig{
+		FadeIn:  TimeFader{Start: "sunset-1h", End: "sunset"},
+		FadeOut: TimeFader{Start: "11:00PM", End: "11:30PM"},
+	}.Compile(chicago)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// sunset is around 8:29PM in june but 4:22PM in december
+	june := time.Date(2025, time.June, 21, 17, 0, 0, 0, l)
+	december := time.Date(2025, time.December, 21, 17, 0, 0, 0, l)
+
+	if got := overlay.Mix(june); got != 0 {
+		t.Fatalf("june at 5PM: got %f", got)
+	}
+
+	if got := overlay.Mix(december); got != 1 {
+		t.Fatalf("december at 5PM: got %f", got)
+	}
+
+	if _, err := (TimeConfig{
+		FadeIn:  TimeFader{Start: "sunset", End: "sunset+1h"},
+		FadeOut: TimeFader{Start: "11:00PM", End: "11:30PM"},
+	}).Compile(nil); err == nil {
+		t.Fatal("expected an error without a location")
+	}
+}
@@ -0,0 +1,221 @@
+package main
+
+import (
+	"encoding/json"
+	"log/slog"
+	"math"
+	"strings"
+	"time"
+
+	mqtt "github.com/eclipse/paho.mqtt.golang"
+)
+
+// how many of our own publishes to remember when checking if a reported color
+// came from lumos. zigbee2mqtt can lag a few messages behind during a
+// transition.
+const publishHistory = 16
+
+// how far off a reported xy can be from what we published and still count as
+// ours. zigbee2mqtt rounds and bulbs quantize, so this can't be exact. Bulbs
+// also report where they are partway through a transition, so anything near
+// the line between two publishes counts too.
+const xyTolerance = 0.02
+
+// same idea for brightness, on the zigbee2mqtt 0 to 254 scale
+const brightnessTolerance = 2
+
+// and for color temperature, in mireds
+const miredsTolerance = 5
+
+type xyPoint struct {
+	X float64 `json:"x"`
+	Y float64 `json:"y"`
+}
+
+// Z2MState is the subset of a zigbee2mqtt device state message lumos cares
+// about.
+type Z2MState struct {
+	State      *string   `json:"state"`
+	Brightness *int      `json:"brightness"`
+	ColorMode  *string   `json:"color_mode"`
+	Color      *Z2MColor `json:"color"`
+	ColorTemp  *int      `json:"color_temp"`
+}
+
+type Z2MColor struct {
+	X *float64 `json:"x"`
+	Y *float64 `json:"y"`
+}
+
+func setupState(client mqtt.Client) {
+	subscribe(client, "zigbee2mqtt/+", 0, onState)
+}
+
+func onState(c mqtt.Client, m mqtt.Message) {
+	name := strings.TrimPrefix(m.Topic(), "zigbee2mqtt/")
+	if name == "bridge" {
+		return
+	}
+
+	var report Z2MState
+	if err := json.Unmarshal(m.Payload(), &report); err != nil {
+		slog.Debug("ignoring unparsable device state", "topic", m.Topic(), "err", err)
+		return
+	}
+
+	manager.Lock()
+	state, ok := manager.lights[name]
+	cooldown := config.Cooldown()
+	manager.Unlock()
+
+	if !ok {
+		return
+	}
+
+	if reason := state.Observe(report); reason != "" {
+		slog.Info("manual change detected, backing off", "friendly_name", name, "reason", reason, "cooldown", cooldown)
+		state.Cooldown(cooldown)
+	}
+}
+
+// Published records a command lumos sent to the light.
+func (s *LightState) Published(cmd Command) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if cmd.xy != nil {
+		s.published = remember(s.published, *cmd.xy)
+	}
+
+	if cmd.ColorTemp != nil {
+		s.publishedMireds = remember(s.publishedMireds, *cmd.ColorTemp)
+	}
+
+	// forget brightness once lumos stops driving it, so it's left to whoever
+	// else wants it
+	if cmd.Brightness != nil {
+		s.publishedBrightness = remember(s.publishedBrightness, *cmd.Brightness)
+	} else {
+		s.publishedBrightness = nil
+	}
+}
+
+func remember[T any](history []T, value T) []T {
+	history = append(history, value)
+	if len(history) > publishHistory {
+		history = history[len(history)-publishHistory:]
+	}
+
+	return history
+}
+
+// Observe folds a state report into what we know about the light and returns
+// a non-empty reason if it looks like something other than lumos changed it.
+func (s *LightState) Observe(report Z2MState) string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	reason := ""
+
+	// turning a light off or on isn't fighting lumos, it just means we should
+	// hold off publishing until it's back on
+	if report.State != nil && *report.State != s.power {
+		s.power = *report.State
+		s.notify()
+	}
+
+	if report.Brightness != nil {
+		// brightness lumos doesn't drive isn't ours to defend
+		if len(s.publishedBrightness) > 0 && s.brightness != nil && *s.brightness != *report.Brightness && s.power != "OFF" && !near(s.publishedBrightness, *report.Brightness, brightnessTolerance) {
+			reason = "brightness changed"
+		}
+
+		brightness := *report.Brightness
+		s.brightness = &brightness
+	}
+
+	// nothing to compare colors against until we've sent something, and an off
+	// light keeps reporting whatever it showed last
+	if (len(s.published) == 0 && len(s.publishedMireds) == 0) || s.power == "OFF" || reason != "" {
+		return reason
+	}
+
+	// tunable white bulbs are driven by color temperature instead
+	if len(s.publishedMireds) > 0 {
+		if report.ColorMode != nil && *report.ColorMode != "color_temp" {
+			return "color mode changed to " + *report.ColorMode
+		}
+
+		if report.ColorTemp != nil && !near(s.publishedMireds, *report.ColorTemp, miredsTolerance) {
+			return "color temperature changed"
+		}
+
+		return ""
+	}
+
+	if report.ColorMode != nil && *report.ColorMode != "xy" && *report.ColorMode != "hs" {
+		return "color mode changed to " + *report.ColorMode
+	}
+
+	if report.Color != nil && report.Color.X != nil && report.Color.Y != nil {
+		if !nearXY(s.published, xyPoint{X: *report.Color.X, Y: *report.Color.Y}) {
+			return "color changed"
+		}
+	}
+
+	return ""
+}
+
+// near reports whether value is within tolerance of anything in history, or of
+// anything between two consecutive entries, where a transition would pass.
+func near(history []int, value, tolerance int) bool {
+	for i, v := range history {
+		from := v
+		if i > 0 {
+			from = history[i-1]
+		}
+
+		if min(from, v)-tolerance <= value && value <= max(from, v)+tolerance {
+			return true
+		}
+	}
+
+	return false
+}
+
+// nearXY is near for colors, measuring how far p is from the line between two
+// consecutive publishes.
+func nearXY(history []xyPoint, p xyPoint) bool {
+	for i, b := range history {
+		a := b
+		if i > 0 {
+			a = history[i-1]
+		}
+
+		// the closest point to p on the segment from a to b
+		dx, dy := b.X-a.X, b.Y-a.Y
+		t := 0.0
+		if length := dx*dx + dy*dy; length > 0 {
+			t = min(max(((p.X-a.X)*dx+(p.Y-a.Y)*dy)/length, 0), 1)
+		}
+
+		if math.Hypot(p.X-(a.X+t*dx), p.Y-(a.Y+t*dy)) <= xyTolerance {
+			return true
+		}
+	}
+
+	return false
+}
+
+// Cooldown pauses the light for d, without shortening a pause that is already
+// in place.
+func (s *LightState) Cooldown(d time.Duration) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.paused && (s.until.IsZero() || s.until.After(time.Now().Add(d))) {
+		return
+	}
+
+	s.pause(d)
+}
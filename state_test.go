@@ -0,0 +1,191 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+func TestObserveDetectsManualChanges(t *testing.T) {
+	str := func(s string) *string { return &s }
+	num := func(i int) *int { return &i }
+	xy := func(x, y float64) *Z2MColor { return &Z2MColor{X: &x, Y: &y} }
+
+	published := Command{xy: &xyPoint{X: 0.3, Y: 0.3}, Brightness: num(100)}
+
+	cases := []struct {
+		name   string
+		sent   []Command
+		before []Z2MState
+		report Z2MState
+		want   string
+	}{
+		{
+			name:   "nothing published yet",
+			report: Z2MState{Color: xy(0.6, 0.3)},
+		},
+		{
+			name:   "our own color",
+			sent:   []Command{published},
+			report: Z2MState{ColorMode: str("xy"), Color: xy(0.31, 0.29)},
+		},
+		{
+			name: "an older publish still in flight",
+			sent: []Command{
+				{xy: &xyPoint{X: 0.5, Y: 0.4}},
+				published,
+			},
+			report: Z2MState{Color: xy(0.5, 0.4)},
+		},
+		{
+			name:   "someone else's color",
+			sent:   []Command{published},
+			report: Z2MState{Color: xy(0.6, 0.3)},
+			want:   "color changed",
+		},
+		{
+			name:   "switched to white",
+			sent:   []Command{published},
+			report: Z2MState{ColorMode: str("color_temp")},
+			want:   "color mode changed to color_temp",
+		},
+		{
+			name:   "off lights report stale colors",
+			sent:   []Command{published},
+			report: Z2MState{State: str("OFF"), Color: xy(0.6, 0.3)},
+		},
+		{
+			name:   "first brightness report",
+			report: Z2MState{Brightness: num(40)},
+		},
+		{
+			name:   "brightness we sent",
+			sent:   []Command{published},
+			before: []Z2MState{{Brightness: num(40)}},
+			report: Z2MState{Brightness: num(101)},
+		},
+		{
+			name:   "dimmed by hand",
+			sent:   []Command{published},
+			before: []Z2MState{{Brightness: num(100)}},
+			report: Z2MState{Brightness: num(200)},
+			want:   "brightness changed",
+		},
+		{
+			name:   "brightness lumos doesn't drive",
+			sent:   []Command{published, {xy: &xyPoint{X: 0.3, Y: 0.3}}},
+			before: []Z2MState{{Brightness: num(100)}},
+			report: Z2MState{Brightness: num(200)},
+		},
+		{
+			name: "partway through a transition",
+			sent: []Command{
+				{xy: &xyPoint{X: 0.3, Y: 0.3}, Brightness: num(50)},
+				{xy: &xyPoint{X: 0.5, Y: 0.4}, Brightness: num(150)},
+			},
+			before: []Z2MState{{Brightness: num(50)}},
+			report: Z2MState{Color: xy(0.41, 0.355), Brightness: num(90)},
+		},
+		{
+			name: "off the way between two publishes",
+			sent: []Command{
+				{xy: &xyPoint{X: 0.3, Y: 0.3}},
+				{xy: &xyPoint{X: 0.5, Y: 0.4}},
+			},
+			report: Z2MState{Color: xy(0.4, 0.4)},
+			want:   "color changed",
+		},
+		{
+			name: "temperature we sent",
+			sent: []Command{{ColorTemp: num(300)}},
+			report: Z2MState{
+				ColorMode: str("color_temp"),
+				ColorTemp: num(303),
+			},
+		},
+		{
+			name:   "temperature partway through a transition",
+			sent:   []Command{{ColorTemp: num(250)}, {ColorTemp: num(400)}},
+			report: Z2MState{ColorTemp: num(320)},
+		},
+		{
+			name:   "temperature changed by hand",
+			sent:   []Command{{ColorTemp: num(300)}},
+			report: Z2MState{ColorTemp: num(400)},
+			want:   "color temperature changed",
+		},
+	}
+
+	for _, c := range cases {
+		state := NewLightState()
+		for _, cmd := range c.sent {
+			state.Published(cmd)
+		}
+
+		for _, report := range c.before {
+			state.Observe(report)
+		}
+
+		if got := state.Observe(c.report); got != c.want {
+			t.Fatalf("%s: got %q want %q", c.name, got, c.want)
+		}
+	}
+}
+
+func TestObservePowerGatesPublishing(t *testing.T) {
+	off, on := "OFF", "ON"
+	state := NewLightState()
+
+	changed := state.Changed()
+	state.Observe(Z2MState{State: &off})
+	if state.Active() || !closed(changed) {
+		t.Fatal("expected switching off to deactivate the light and notify")
+	}
+
+	changed = state.Changed()
+	state.Observe(Z2MState{State: &off})
+	if closed(changed) {
+		t.Fatal("expected a repeated state not to notify")
+	}
+
+	state.Observe(Z2MState{State: &on})
+	if !state.Active() {
+		t.Fatal("expected switching on to reactivate the light")
+	}
+}
+
+func TestPublishHistoryIsBounded(t *testing.T) {
+	state := NewLightState()
+	for i := range publishHistory + 1 {
+		state.Published(Command{xy: &xyPoint{X: 0.1 + float64(i)*0.03, Y: 0.3}})
+	}
+
+	// the very first publish has been pushed out
+	x, y := 0.1, 0.3
+	if got := state.Observe(Z2MState{Color: &Z2MColor{X: &x, Y: &y}}); got != "color changed" {
+		t.Fatalf("expected a forgotten publish not to match, got %q", got)
+	}
+}
+
+func TestCooldownKeepsLongerPause(t *testing.T) {
+	state := NewLightState()
+
+	state.Pause(0)
+	state.Cooldown(time.Minute)
+
+	var status LightStatus
+	state.fillStatus(&status)
+	if status.PausedUntil != nil {
+		t.Fatalf("expected cooldown not to shorten an indefinite pause, got until %s", status.PausedUntil)
+	}
+
+	state.Resume()
+	state.Cooldown(time.Hour)
+	state.Cooldown(time.Minute)
+
+	state.fillStatus(&status)
+	if status.PausedUntil == nil || time.Until(*status.PausedUntil) < 59*time.Minute {
+		t.Fatalf("expected the hour long cooldown to stick, got %v", status.PausedUntil)
+	}
+
+	state.Resume()
+}
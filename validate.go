@@ -0,0 +1,338 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"slices"
+	"strings"
+	"time"
+)
+
+// ValidationError is a single problem with the config, along with the JSON
+// path of the value that caused it.
+type ValidationError struct {
+	Path string
+	Err  error
+}
+
+func (e ValidationError) Error() string {
+	if e.Path == "" {
+		return e.Err.Error()
+	}
+
+	return fmt.Sprintf("%s: %s", e.Path, e.Err)
+}
+
+func (e ValidationError) Unwrap() error {
+	return e.Err
+}
+
+// ValidationErrors collects every problem found in a config so they can all be
+// reported at once instead of fixing them one restart at a time.
+type ValidationErrors []ValidationError
+
+func (e ValidationErrors) Error() string {
+	if len(e) == 1 {
+		return e[0].Error()
+	}
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "%d problems found:", len(e))
+	for _, err := range e {
+		b.WriteString("\n  ")
+		b.WriteString(err.Error())
+	}
+
+	return b.String()
+}
+
+// Add records err at path. Nested ValidationErrors are flattened with their
+// paths prefixed. A nil err is ignored.
+func (e *ValidationErrors) Add(path string, err error) {
+	if err == nil {
+		return
+	}
+
+	var nested ValidationErrors
+	if errors.As(err, &nested) {
+		for _, inner := range nested {
+			*e = append(*e, ValidationError{Path: joinPath(path, inner.Path), Err: inner.Err})
+		}
+
+		return
+	}
+
+	*e = append(*e, ValidationError{Path: path, Err: err})
+}
+
+// Err returns the collected errors, or nil if there weren't any.
+func (e ValidationErrors) Err() error {
+	if len(e) == 0 {
+		return nil
+	}
+
+	return e
+}
+
+// collect runs parse on input, recording any error at path, so a struct can be
+// built from several fallible parses while still reporting all of them.
+func collect[I, T any](errs *ValidationErrors, path string, parse func(I) (T, error), input I) T {
+	val, err := parse(input)
+	errs.Add(path, err)
+	return val
+}
+
+func joinPath(prefix, path string) string {
+	switch {
+	case prefix == "":
+		return path
+	case path == "":
+		return prefix
+	case strings.HasPrefix(path, "["):
+		return prefix + path
+	default:
+		return prefix + "." + path
+	}
+}
+
+// decodeConfig unmarshals a config file on top of the defaults, turning json
+// errors into something pointing at where in the file the problem is.
+func decodeConfig(contents []byte) (Config, error) {
+	data := defaultConfig()
+
+	decoder := json.NewDecoder(bytes.NewReader(contents))
+	decoder.DisallowUnknownFields()
+
+	err := decoder.Decode(&data)
+
+	// configs written before unknown fields were checked may have leftovers
+	// that used to be ignored, so only warn about them instead of refusing to
+	// start
+	if err != nil && strings.HasPrefix(err.Error(), "json: unknown field ") {
+		slog.Warn("ignoring unknown config field, this will be an error in a future release", "err", err)
+
+		data = defaultConfig()
+		err = json.Unmarshal(contents, &data)
+	}
+
+	if err != nil {
+		var syntaxErr *json.SyntaxError
+		if errors.As(err, &syntaxErr) {
+			line, col := position(contents, syntaxErr.Offset)
+			return Config{}, fmt.Errorf("invalid json at line %d, column %d: %w", line, col, err)
+		}
+
+		var typeErr *json.UnmarshalTypeError
+		if errors.As(err, &typeErr) {
+			return Config{}, ValidationErrors{{
+				Path: typeErr.Field,
+				Err:  fmt.Errorf("expected %s, got %s", typeErr.Type, typeErr.Value),
+			}}
+		}
+
+		return Config{}, err
+	}
+
+	return data, nil
+}
+
+// line and column, both 1 based, of a byte offset
+func position(contents []byte, offset int64) (int, int) {
+	before := contents[:min(int(offset), len(contents))]
+	line := bytes.Count(before, []byte("\n")) + 1
+	col := len(before) - bytes.LastIndexByte(before, '\n')
+	return line, col
+}
+
+func (c *Config) Validate() error {
+	var errs ValidationErrors
+
+	if c.Steps == 0 {
+		errs.Add("steps", errors.New("must be at least 1"))
+	}
+
+	errs.Add("transition", c.Transition.Validate(false))
+	errs.Add("hold", c.Hold.Validate(true))
+
+	if c.ManualCooldown != "" {
+		if _, err := parseDuration(c.ManualCooldown); err != nil {
+			errs.Add("manual_cooldown", err)
+		}
+	}
+
+	if c.Location != nil {
+		errs.Add("location", c.Location.Validate())
+	}
+
+	for i, group := range c.Groups {
+		errs.Add(fmt.Sprintf("groups[%d]", i), group.Validate(c.Location))
+	}
+
+	c.validateAmbients(&errs)
+
+	for i, gamut := range c.Gamuts {
+		_, err := gamut.Compile()
+		errs.Add(fmt.Sprintf("gamuts[%d]", i), err)
+	}
+
+	for i, model := range c.NoTransition {
+		if model == "" {
+			errs.Add(fmt.Sprintf("no_transition[%d]", i), errors.New("model can't be empty"))
+		}
+	}
+
+	return errs.Err()
+}
+
+// validateAmbients checks every light an overlay can apply to has an ambient
+// color to fall back on while the overlay is inactive. Which lights are in
+// which zigbee2mqtt group isn't known until runtime, so every group named by
+// an overlay needs an ambient group of its own.
+func (c *Config) validateAmbients(errs *ValidationErrors) {
+	for i, group := range c.Groups {
+		if group.IsAmbient() {
+			continue
+		}
+
+		if len(group.AppliesTo) == 0 && !c.hasAmbient("") {
+			errs.Add(fmt.Sprintf("groups[%d]", i), errors.New("applies to every light, but no ambient group does, so lights would have nothing to show while it's inactive"))
+		}
+
+		for j, name := range group.AppliesTo {
+			if !c.hasAmbient(name) {
+				errs.Add(fmt.Sprintf("groups[%d].applies_to[%d]", i, j), fmt.Errorf("no ambient group applies to %q, so its lights would have nothing to show while this group is inactive", name))
+			}
+		}
+	}
+}
+
+// hasAmbient reports whether an ambient group covers every light in the
+// zigbee2mqtt group name, or every light at all when name is empty. A wave's
+// colors only cover its own lights, so waves don't count.
+func (c *Config) hasAmbient(name string) bool {
+	for _, group := range c.Groups {
+		if group.Mode != ModeWave && group.IsAmbient() && group.Contains([]string{name}) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// Validate checks that both ends parse and form a sensible range. Zero
+// durations are only allowed when allowZero is set.
+func (t Transition) Validate(allowZero bool) error {
+	var errs ValidationErrors
+
+	minimum := collect(&errs, "min", parseDuration, t.Minimum)
+	maximum := collect(&errs, "max", parseDuration, t.Maximum)
+	if len(errs) > 0 {
+		return errs
+	}
+
+	if minimum < 0 || (!allowZero && minimum == 0) {
+		errs.Add("min", fmt.Errorf("must be positive, got %s", t.Minimum))
+	}
+
+	if minimum > maximum {
+		errs.Add("", fmt.Errorf("min %s is greater than max %s", t.Minimum, t.Maximum))
+	}
+
+	return errs.Err()
+}
+
+func parseDuration(s string) (time.Duration, error) {
+	if s == "" {
+		return 0, errors.New("duration is required")
+	}
+
+	d, err := time.ParseDuration(s)
+	if err != nil {
+		return 0, fmt.Errorf("invalid duration %q, expected something like 1m30s", s)
+	}
+
+	return d, nil
+}
+
+func (g *GroupConfig) Validate(location *Coordinates) error {
+	var errs ValidationErrors
+
+	if len(g.Colors) == 0 {
+		errs.Add("colors", errors.New("must have at least one color"))
+	}
+
+	switch g.Mode {
+	case "", ModeIndependent:
+	case ModeSync:
+		if len(g.AppliesTo) == 0 {
+			errs.Add("mode", errors.New("sync needs applies_to to list the zigbee2mqtt groups to sync"))
+		}
+	case ModeWave:
+		if len(g.Order) == 0 {
+			errs.Add("order", errors.New("wave needs at least one light to run along"))
+		}
+
+		for i, name := range g.Order {
+			if slices.Contains(g.Order[:i], name) {
+				errs.Add(fmt.Sprintf("order[%d]", i), fmt.Errorf("%q is listed more than once", name))
+			}
+		}
+
+		if offset := collect(&errs, "offset", parseDuration, g.Offset); offset < 0 {
+			errs.Add("offset", fmt.Errorf("must not be negative, got %s", g.Offset))
+		}
+	default:
+		errs.Add("mode", fmt.Errorf("unknown mode %q, expected independent, sync or wave", g.Mode))
+	}
+
+	if g.Mode != ModeWave && (len(g.Order) > 0 || g.Offset != "") {
+		errs.Add("order", errors.New("order and offset only apply to wave mode"))
+	}
+
+	for i, color := range g.Colors {
+		_, err := color.Compile()
+		errs.Add(fmt.Sprintf("colors[%d]", i), err)
+
+		// other strategies would quietly ignore it
+		if color.Weight != nil && g.Selection != "" && g.Selection != SelectWeighted {
+			errs.Add(fmt.Sprintf("colors[%d].weight", i), fmt.Errorf("only applies to weighted selection, not %s", g.Selection))
+		}
+	}
+
+	if g.Selection != "" && !slices.Contains(selectionStrategies, g.Selection) {
+		errs.Add("selection", fmt.Errorf("unknown selection %q, expected uniform, weighted, sequential, shuffle_bag or max_distance", g.Selection))
+	}
+
+	if g.Time != nil {
+		_, err := g.Time.Compile(location)
+		errs.Add("time", err)
+	}
+
+	if g.Date != nil {
+		_, err := g.Date.Compile()
+		errs.Add("date", err)
+	}
+
+	if g.Weekdays != nil {
+		_, err := g.Weekdays.Compile()
+		errs.Add("weekdays", err)
+	}
+
+	if g.Calendar != nil {
+		_, err := g.Calendar.Compile(nil)
+		errs.Add("calendar", err)
+	}
+
+	if g.Brightness != nil {
+		_, err := g.Brightness.Compile()
+		errs.Add("brightness", err)
+	}
+
+	collect(&errs, "interpolation", parseInterpolationSpace, g.Interpolation)
+	collect(&errs, "easing", parseEasing, g.Easing)
+
+	return errs.Err()
+}
@@ -0,0 +1,135 @@
+package main
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestParseConfigValid(t *testing.T) {
+	_, err := ParseConfig([]byte(`{
+		"steps": 5,
+		"transition": {"min": "1s", "max": "2s"},
+		"hold": {"min": "0s", "max": "1s"},
+		"groups": [
+			{"colors": ["#fff", "oklch(43.8% 0.218 303.724)"]},
+			{
+				"colors": ["#ff0000", {"color": "#00ff00", "weight": 3}],
+				"selection": "weighted",
+				"interpolation": "oklab",
+				"easing": "cubic-bezier(0.4, 0, 0.2, 1)"
+			},
+			{
+				"colors": ["#ff8800"],
+				"time": {
+					"fade_in": {"start": "5:00PM", "end": "6:00PM"},
+					"fade_out": {"start": "11:00PM", "end": "11:30PM"}
+				}
+			}
+		]
+	}`))
+	if err != nil {
+		t.Fatalf("expected valid config, got %v", err)
+	}
+}
+
+func TestParseConfigCollectsErrors(t *testing.T) {
+	_, err := ParseConfig([]byte(`{
+		"steps": 0,
+		"transition": {"min": "5s", "max": "2s"},
+		"hold": {"min": "0s", "max": "nope"},
+		"groups": [
+			{"colors": ["#ggg", "oklch(50% 0.1)", {"color": "#fff", "weight": -1}]},
+			{
+				"colors": [],
+				"time": {
+					"fade_in": {"start": "3:00 PM", "end": "6:00PM"},
+					"fade_out": {"start": "11:00PM", "end": "11:30PM"}
+				}
+			}
+		]
+	}`))
+
+	var errs ValidationErrors
+	if !errors.As(err, &errs) {
+		t.Fatalf("expected validation errors, got %v", err)
+	}
+
+	want := []string{
+		"steps",
+		"transition",
+		"hold.max",
+		"groups[0].colors[0]",
+		"groups[0].colors[1]",
+		"groups[0].colors[2].weight",
+		"groups[1].colors",
+		"groups[1].time.fade_in.start",
+	}
+
+	if len(errs) != len(want) {
+		t.Fatalf("expected %d errors, got %d: %v", len(want), len(errs), err)
+	}
+
+	for i, path := range want {
+		if errs[i].Path != path {
+			t.Fatalf("error %d: got path %q want %q", i, errs[i].Path, path)
+		}
+	}
+}
+
+func TestParseConfigSyntaxError(t *testing.T) {
+	_, err := ParseConfig([]byte("{\n  \"steps\": 5,\n  \"groups\": [}\n"))
+	if err == nil || !strings.Contains(err.Error(), "line 3") {
+		t.Fatalf("expected syntax error on line 3, got %v", err)
+	}
+}
+
+func TestParseConfigRequiresAmbients(t *testing.T) {
+	_, err := ParseConfig([]byte(`{
+		"steps": 5,
+		"transition": {"min": "1s", "max": "2s"},
+		"hold": {"min": "0s", "max": "1s"},
+		"groups": [
+			{"colors": ["#fff"], "applies_to": ["kitchen"]},
+			{
+				"colors": ["#ff8800"],
+				"applies_to": ["kitchen", "bedroom"],
+				"weekdays": {"days": ["sat", "sun"]}
+			},
+			{"colors": ["#0000ff"], "weekdays": {"days": ["mon"]}}
+		]
+	}`))
+
+	var errs ValidationErrors
+	if !errors.As(err, &errs) {
+		t.Fatalf("expected validation errors, got %v", err)
+	}
+
+	want := []string{"groups[1].applies_to[1]", "groups[2]"}
+	if len(errs) != len(want) {
+		t.Fatalf("expected %d errors, got %d: %v", len(want), len(errs), err)
+	}
+
+	for i, path := range want {
+		if errs[i].Path != path {
+			t.Fatalf("error %d: got path %q want %q", i, errs[i].Path, path)
+		}
+	}
+}
+
+func TestParseConfigIgnoresUnknownFields(t *testing.T) {
+	cfg, err := ParseConfig([]byte(`{
+		"steps": 5,
+		"transition": {"min": "1s", "max": "2s"},
+		"hold": {"min": "0s", "max": "1s"},
+		"retired_option": true,
+		"groups": [{"colors": ["#fff"], "legacy": 1}]
+	}`))
+	if err != nil {
+		t.Fatalf("expected unknown fields to only warn, got %v", err)
+	}
+
+	if cfg.Steps != 5 || len(cfg.Groups) != 1 {
+		t.Fatalf("expected the rest of the config to load, got %+v", cfg)
+	}
+}